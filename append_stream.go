@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/thomasylee/GoRaft/rpc"
+)
+
+// appendStream sends a pipeline's AppendEntries requests to a follower over a
+// single long-lived stream. One goroutine sends the requests in order, and
+// another matches each response to its request and reports it to the
+// pipeline, until the stream fails or is closed.
+type appendStream struct {
+	stream   *rpc.AppendEntriesStream
+	requests chan appendResult
+	sent     chan appendResult
+	closed   chan bool
+
+	// The requests sent over the stream that haven't been reported yet. These
+	// are only used by the pipeline.
+	inflight      int
+	inflightBytes int
+}
+
+// openAppendStream opens a stream to the follower at the address, reporting
+// the outcome of each request sent over it to results.
+func openAppendStream(address string, results chan<- appendResult) (*appendStream, error) {
+	stream, err := rpc.OpenAppendEntriesStream(address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &appendStream{
+		stream: stream,
+		// The pipeline never has more than maxInflightRequests requests in
+		// flight, so sending never blocks.
+		requests: make(chan appendResult, maxInflightRequests()),
+		sent:     make(chan appendResult, maxInflightRequests()),
+		closed:   make(chan bool),
+	}
+	go s.sendRequests()
+	go s.receiveResponses(results)
+	return s, nil
+}
+
+// send queues the request to be sent down the stream.
+func (s *appendStream) send(result appendResult) {
+	result.stream = s
+	s.inflight++
+	s.inflightBytes += result.size
+	s.requests <- result
+}
+
+// close closes the stream and stops its goroutines.
+func (s *appendStream) close() {
+	close(s.closed)
+	s.stream.Close()
+}
+
+// sendRequests sends the queued requests until one can't be sent.
+func (s *appendStream) sendRequests() {
+	for {
+		var result appendResult
+		select {
+		case <-s.closed:
+			return
+		case result = <-s.requests:
+		}
+
+		// A request that can't be sent is still passed on, since receiving
+		// returns the error that ended the stream.
+		err := s.stream.Send(result.request)
+		select {
+		case <-s.closed:
+			return
+		case s.sent <- result:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// receiveResponses reports the response to each request sent, in order,
+// until the stream fails.
+func (s *appendStream) receiveResponses(results chan<- appendResult) {
+	for {
+		var result appendResult
+		select {
+		case <-s.closed:
+			return
+		case result = <-s.sent:
+		}
+
+		result.response, result.err = s.stream.Recv()
+		select {
+		case <-s.closed:
+			return
+		case results <- result:
+		}
+		if result.err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// testFollower is a GoRaft server that answers AppendEntries requests with
+// respond, which returns nil to leave a request unanswered, and RequestVote
+// requests with vote if it is set.
+type testFollower struct {
+	respond func(request *rpc.AppendEntriesRequest) *rpc.AppendEntriesResponse
+	vote    func(request *rpc.RequestVoteRequest) *rpc.RequestVoteResponse
+
+	// Whether streams are supported, and how many streamed requests are
+	// answered before the stream is ended with an error. Zero means no limit.
+	streams      bool
+	streamLength int
+
+	mutex         sync.Mutex
+	unaryCount    int
+	streamCount   int
+	streamsOpened int
+}
+
+func (f *testFollower) AppendEntries(ctx context.Context, request *rpc.AppendEntriesRequest) (*rpc.AppendEntriesResponse, error) {
+	f.mutex.Lock()
+	f.unaryCount++
+	f.mutex.Unlock()
+	return f.respond(request), nil
+}
+
+func (f *testFollower) AppendEntriesStream(stream rpc.GoRaft_AppendEntriesStreamServer) error {
+	if !f.streams {
+		return status.Error(codes.Unimplemented, "streams are not supported")
+	}
+	f.mutex.Lock()
+	f.streamsOpened++
+	f.mutex.Unlock()
+
+	for answered := 0; ; answered++ {
+		if f.streamLength > 0 && answered == f.streamLength {
+			return errors.New("stream broke")
+		}
+		request, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		f.mutex.Lock()
+		f.streamCount++
+		f.mutex.Unlock()
+
+		response := f.respond(request)
+		if response == nil {
+			continue
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *testFollower) RequestVote(ctx context.Context, request *rpc.RequestVoteRequest) (*rpc.RequestVoteResponse, error) {
+	if f.vote != nil {
+		return f.vote(request), nil
+	}
+	return nil, status.Error(codes.Unimplemented, "not supported")
+}
+
+func (f *testFollower) InstallSnapshot(stream rpc.GoRaft_InstallSnapshotServer) error {
+	return status.Error(codes.Unimplemented, "not supported")
+}
+
+func (f *testFollower) TimeoutNow(ctx context.Context, request *rpc.TimeoutNowRequest) (*rpc.TimeoutNowResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "not supported")
+}
+
+// counts returns the number of unary and streamed requests received, and the
+// number of streams opened.
+func (f *testFollower) counts() (int, int, int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.unaryCount, f.streamCount, f.streamsOpened
+}
+
+// succeed answers every request with a success in the request's term.
+func succeed(request *rpc.AppendEntriesRequest) *rpc.AppendEntriesResponse {
+	return &rpc.AppendEntriesResponse{Term: request.Term, Success: true}
+}
+
+// startTestFollower serves the follower on a free local port, returning its
+// address and a function that stops it.
+func startTestFollower(t *testing.T, follower *testFollower) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	rpc.RegisterGoRaftServer(server, follower)
+	go server.Serve(listener)
+
+	address := listener.Addr().String()
+	return address, func() {
+		rpc.Pool.Remove(address)
+		server.Stop()
+	}
+}
+
+// receiveResult returns the next result reported to results, failing the test
+// if none arrives within a few seconds.
+func receiveResult(t *testing.T, results <-chan appendResult) appendResult {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("No result was reported")
+		return appendResult{}
+	}
+}
+
+func Test_appendStream_WithSeveralRequests_ReportsResponsesInOrder(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	// Each response carries the request's PrevLogIndex as its term, so that
+	// it can be matched to the request.
+	address, stop := startTestFollower(t, &testFollower{
+		streams: true,
+		respond: func(request *rpc.AppendEntriesRequest) *rpc.AppendEntriesResponse {
+			return &rpc.AppendEntriesResponse{Term: request.PrevLogIndex, Success: true}
+		},
+	})
+	defer stop()
+
+	results := make(chan appendResult, maxInflightRequests())
+	stream, err := openAppendStream(address, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.close()
+
+	for i := 1; i <= 5; i++ {
+		stream.send(appendResult{request: &rpc.AppendEntriesRequest{PrevLogIndex: uint32(i)}, size: 10})
+	}
+	if stream.inflight != 5 || stream.inflightBytes != 50 {
+		t.Error("Requests were not counted as in flight:", stream.inflight, stream.inflightBytes)
+	}
+
+	for i := 1; i <= 5; i++ {
+		result := receiveResult(t, results)
+		if result.err != nil {
+			t.Fatal(result.err)
+		}
+		if result.stream != stream || result.request.PrevLogIndex != uint32(i) || result.response.Term != uint32(i) {
+			t.Errorf("Result %d was for request %d with response %d", i, result.request.PrevLogIndex, result.response.Term)
+		}
+	}
+}
+
+func Test_appendStream_WhenFollowerDoesNotRespond_FailsAfterRpcTimeout(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+	global.Config.RpcTimeout = 100
+	defer func() { global.Config.RpcTimeout = 0 }()
+
+	address, stop := startTestFollower(t, &testFollower{
+		streams: true,
+		respond: func(request *rpc.AppendEntriesRequest) *rpc.AppendEntriesResponse { return nil },
+	})
+	defer stop()
+
+	results := make(chan appendResult, maxInflightRequests())
+	stream, err := openAppendStream(address, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.close()
+
+	start := time.Now()
+	stream.send(appendResult{request: &rpc.AppendEntriesRequest{}})
+	result := receiveResult(t, results)
+	if result.err == nil {
+		t.Error("Unanswered request did not fail")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Error("Request failed before the RPC timeout:", elapsed)
+	}
+}
+
+// newStreamingPipeline returns a pipeline to the follower at the address that
+// uses streams, for the leader created by createLeaderState, which becomes the
+// node's state.
+func newStreamingPipeline(address string, stop <-chan bool) *pipeline {
+	nodeState := createLeaderState(3)
+	state.Node = nodeState
+
+	p := newTestPipeline(nodeState)
+	p.address = address
+	p.results = make(chan appendResult, maxInflightRequests())
+	p.stop = stop
+	p.useStream = true
+	return p
+}
+
+func Test_pipeline_WhenFollowerDoesNotSupportStreams_FallsBackToUnary(t *testing.T) {
+	follower := &testFollower{respond: succeed}
+	address, stopFollower := startTestFollower(t, follower)
+	defer stopFollower()
+
+	stop := make(chan bool)
+	defer close(stop)
+	p := newStreamingPipeline(address, stop)
+
+	p.openStream()
+	if p.stream == nil {
+		t.Fatal("Stream was not opened")
+	}
+	p.send(true)
+	p.receive(receiveResult(t, p.results))
+	if p.useStream || p.stream != nil {
+		t.Error("Pipeline kept using streams:", p.useStream)
+	}
+
+	p.send(true)
+	result := receiveResult(t, p.results)
+	if result.err != nil || result.stream != nil {
+		t.Error("Request was not sent with the unary RPC:", result.err)
+	}
+	p.receive(result)
+
+	unary, streamed, _ := follower.counts()
+	if unary != 1 || streamed != 0 {
+		t.Errorf("Follower received %d unary and %d streamed requests", unary, streamed)
+	}
+	if p.inflight != 0 || p.probing {
+		t.Error("Unary response was not handled:", p.inflight, p.probing)
+	}
+}
+
+func Test_pipeline_WhenStreamBreaks_SendsUnaryRequestsUntilReopened(t *testing.T) {
+	follower := &testFollower{respond: succeed, streams: true, streamLength: 1}
+	address, stopFollower := startTestFollower(t, follower)
+	defer stopFollower()
+
+	stop := make(chan bool)
+	defer close(stop)
+	p := newStreamingPipeline(address, stop)
+
+	// The first request is answered and the second one breaks the stream.
+	p.openStream()
+	p.send(true)
+	p.receive(receiveResult(t, p.results))
+	p.send(true)
+	result := receiveResult(t, p.results)
+	if result.err == nil || result.stream == nil {
+		t.Fatal("Streamed request did not fail:", result.err)
+	}
+	p.receive(result)
+	if p.stream != nil || !p.useStream || p.inflight != 0 {
+		t.Error("Broken stream was not closed:", p.useStream, p.inflight)
+	}
+
+	// Until the stream is reopened, requests are sent with the unary RPC.
+	p.send(true)
+	result = receiveResult(t, p.results)
+	if result.err != nil || result.stream != nil {
+		t.Error("Request was not sent with the unary RPC:", result.err)
+	}
+	p.receive(result)
+
+	unary, _, opened := follower.counts()
+	if unary != 1 || opened != 1 {
+		t.Errorf("Follower received %d unary requests and %d streams", unary, opened)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// How long to wait before retrying after failing to apply an entry.
+const applyRetryDelay time.Duration = time.Second
+
+// runApplier applies committed log entries to the storage data store,
+// waking up whenever the commit index changes.
+func runApplier() {
+	nodeState := state.GetNodeState()
+	for {
+		err := nodeState.ApplyCommittedEntries()
+		if err == state.ErrSnapshotMissing {
+			// The entries can never be applied, so the node's state is corrupt.
+			global.Log.Panic("Failed to apply log entries:", err.Error())
+		} else if err != nil {
+			global.Log.Error("Failed to apply log entries:", err.Error())
+			time.Sleep(applyRetryDelay)
+			continue
+		}
+
+		err = takeSnapshotIfNeeded(nodeState)
+		if err != nil {
+			global.Log.Error("Failed to take snapshot:", err.Error())
+		}
+
+		nodeState.Lock()
+		changed := nodeState.CommitIndexChanged()
+		caughtUp := nodeState.LastApplied >= nodeState.CommitIndex
+		nodeState.Unlock()
+
+		if caughtUp {
+			<-changed
+		}
+	}
+}
+
+// takeSnapshotIfNeeded takes a snapshot and compacts the log once at least
+// snapshot_threshold entries have been applied since the latest snapshot.
+func takeSnapshotIfNeeded(nodeState *state.NodeState) error {
+	threshold := global.Config.SnapshotThreshold
+	if threshold == 0 {
+		return nil
+	}
+
+	nodeState.Lock()
+	needed := nodeState.LastApplied >= nodeState.SnapshotIndex()+threshold
+	nodeState.Unlock()
+
+	if !needed {
+		return nil
+	}
+	return nodeState.TakeSnapshot(global.Config.SnapshotTrailingEntries)
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/rpc"
+)
+
+// Default values for the retry settings of a new Client.
+const (
+	DefaultAttemptTimeout time.Duration = time.Second
+	DefaultInitialBackoff time.Duration = 50 * time.Millisecond
+	DefaultMaxBackoff     time.Duration = 2 * time.Second
+)
+
+// ErrNoEndpoints is returned by NewClient when no endpoints are given.
+var ErrNoEndpoints = errors.New("client: at least one endpoint is required")
+
+// Client reads and writes key-value pairs in a GoRaft cluster. It finds the
+// leader by following the NotLeader hints returned by followers, caches it
+// for later requests, and retries requests with exponential backoff when the
+// leader is unavailable or changes.
+//
+// Gets are retried after any error that a retry could fix. Puts and Deletes
+// are only retried if the node couldn't have accepted them, because it wasn't
+// the leader or couldn't be reached. Otherwise the write may have been
+// committed, and retrying it could overwrite a newer write from another
+// client, so the ambiguous error is returned instead.
+type Client struct {
+	// The client API addresses (host:port) of the nodes in the cluster.
+	endpoints []string
+
+	// The maximum amount of time a single attempt of a request may take.
+	AttemptTimeout time.Duration
+
+	// The delay before the first retry, which doubles after each retry up to
+	// MaxBackoff. Each delay is randomized to between half and all of it.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	mutex sync.Mutex
+
+	// The address of the last known leader, or an empty string if unknown.
+	leader string
+
+	// The index of the next endpoint to try when the leader is unknown.
+	nextEndpoint int
+
+	// Connections to each address that has been sent a request.
+	conns map[string]*grpc.ClientConn
+}
+
+// NewClient returns a Client that sends requests to the given endpoints, which
+// are the api_port addresses (host:port) of the nodes in the cluster.
+func NewClient(endpoints []string) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	return &Client{
+		endpoints:      append([]string{}, endpoints...),
+		AttemptTimeout: DefaultAttemptTimeout,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+		conns:          make(map[string]*grpc.ClientConn),
+	}, nil
+}
+
+// Put stores the key-value pair, returning once the leader has committed and
+// applied it.
+func (c *Client) Put(ctx context.Context, key string, value string) error {
+	return c.call(ctx, false, func(ctx context.Context, kv rpc.KeyValueStoreClient) error {
+		_, err := kv.Put(ctx, &rpc.PutRequest{Key: key, Value: value})
+		return err
+	})
+}
+
+// Get returns the value of the key, or an empty string if it does not exist.
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.call(ctx, true, func(ctx context.Context, kv rpc.KeyValueStoreClient) error {
+		response, err := kv.Get(ctx, &rpc.GetRequest{Key: key})
+		if err != nil {
+			return err
+		}
+		value = response.Value
+		return nil
+	})
+	return value, err
+}
+
+// Delete removes the key, returning once the leader has committed and applied
+// the change.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.call(ctx, false, func(ctx context.Context, kv rpc.KeyValueStoreClient) error {
+		_, err := kv.Delete(ctx, &rpc.DeleteRequest{Key: key})
+		return err
+	})
+}
+
+// Leader returns the address of the last known leader, or an empty string if
+// the leader is not known.
+func (c *Client) Leader() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.leader
+}
+
+// Close closes all the client's connections.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var err error
+	for address, conn := range c.conns {
+		if closeErr := conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(c.conns, address)
+	}
+	return err
+}
+
+// call runs the request against the leader until it succeeds, fails with an
+// error that can't be retried, or ctx is done. Only idempotent requests are
+// retried after errors that leave it unknown whether the request succeeded.
+func (c *Client) call(ctx context.Context, idempotent bool, request func(context.Context, rpc.KeyValueStoreClient) error) error {
+	backoff := c.InitialBackoff
+	hintsFollowed := 0
+	for {
+		address := c.target()
+		sent, err := c.attempt(ctx, address, request)
+		if err == nil {
+			c.setLeader(address)
+			return nil
+		}
+
+		// Go straight to the leader that the node suggested, unless the hints
+		// keep leading in circles.
+		if notLeader, ok := rpc.LeaderHint(err); ok && notLeader.LeaderAddress != "" && notLeader.LeaderAddress != address {
+			c.setLeader(notLeader.LeaderAddress)
+			if hintsFollowed < len(c.endpoints) {
+				hintsFollowed++
+				continue
+			}
+		} else if !isRetryable(ctx, err, sent, idempotent) {
+			return err
+		} else {
+			c.forgetLeader(address)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(jitter(backoff)):
+		}
+
+		hintsFollowed = 0
+		backoff *= 2
+		if backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+	}
+}
+
+// attempt sends the request to the address once, limited to AttemptTimeout.
+// It returns false if the request was never sent because the node couldn't be
+// reached.
+func (c *Client) attempt(ctx context.Context, address string, request func(context.Context, rpc.KeyValueStoreClient) error) (bool, error) {
+	conn, err := c.connection(address)
+	if err != nil {
+		return false, status.Error(codes.Unavailable, err.Error())
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, c.AttemptTimeout)
+	defer cancel()
+
+	// A request sent over a connection that isn't ready may or may not reach
+	// the node, so the connection is established first.
+	if !waitUntilReady(attemptCtx, conn) {
+		return false, status.Error(codes.Unavailable, "failed to connect to "+address)
+	}
+	return true, request(attemptCtx, rpc.NewKeyValueStoreClient(conn))
+}
+
+// waitUntilReady waits until the connection is ready to send requests,
+// returning false if ctx is done first.
+func waitUntilReady(ctx context.Context, conn *grpc.ClientConn) bool {
+	for {
+		connState := conn.GetState()
+		if connState == connectivity.Ready {
+			return true
+		} else if !conn.WaitForStateChange(ctx, connState) {
+			return false
+		}
+	}
+}
+
+// isRetryable returns true if a request that failed with err could succeed
+// if it is sent again, possibly to a different node. A request that was sent
+// and failed with an error other than a NotLeader rejection may have
+// succeeded, so it is only retryable if it is idempotent.
+func isRetryable(ctx context.Context, err error, sent bool, idempotent bool) bool {
+	if ctx.Err() != nil {
+		return false
+	} else if !sent {
+		return true
+	}
+
+	switch status.Code(err) {
+	case codes.FailedPrecondition:
+		_, ok := rpc.LeaderHint(err)
+		return ok || idempotent
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+		return idempotent
+	}
+	return false
+}
+
+// jitter returns a random duration between half of backoff and backoff, so
+// that clients that failed at the same time don't retry in lockstep.
+func jitter(backoff time.Duration) time.Duration {
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// target returns the address to send the next request to: the leader if it
+// is known, or otherwise the next endpoint in turn.
+func (c *Client) target() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.leader != "" {
+		return c.leader
+	}
+
+	address := c.endpoints[c.nextEndpoint]
+	c.nextEndpoint = (c.nextEndpoint + 1) % len(c.endpoints)
+	return address
+}
+
+// setLeader caches the address of the leader.
+func (c *Client) setLeader(address string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.leader = address
+}
+
+// forgetLeader clears the cached leader if it is the given address.
+func (c *Client) forgetLeader(address string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.leader == address {
+		c.leader = ""
+	}
+}
+
+// connection returns the connection to the address, dialing it if the client
+// has not connected to it before.
+func (c *Client) connection(address string) (*grpc.ClientConn, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if conn, ok := c.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	c.conns[address] = conn
+	return conn, nil
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// The test server's client API port, and an address that nothing listens on.
+const (
+	apiPort     string = "8100"
+	deadAddress string = "127.0.0.1:8199"
+)
+
+func TestMain(m *testing.M) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	// Run a single node cluster so that the test server is always the leader.
+	global.Config.NodeId = "1"
+	global.Config.Nodes = map[string]global.NodeHost{"1": {Url: "127.0.0.1", ApiPort: 8100}}
+
+	state.Node = state.NewNodeState(
+		state.NewMemoryDataStore(),
+		state.NewMemoryDataStore(),
+		state.NewMemorySnapshotStore())
+	state.Node.Lock()
+	state.Node.Bootstrap(global.Config.Nodes)
+	term, _ := state.Node.BecomeCandidate("1")
+	state.Node.BecomeLeader(term, "1", state.Node.Membership().NodeIds())
+	state.Node.Unlock()
+
+	go func() {
+		for {
+			state.Node.ApplyCommittedEntries()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	go rpc.RunApiServer(apiPort)
+	// Give the server a few seconds to start.
+	time.Sleep(3 * time.Second)
+
+	os.Exit(m.Run())
+}
+
+func Test_NewClient_WithNoEndpoints_ReturnsErrNoEndpoints(t *testing.T) {
+	_, err := NewClient([]string{})
+	if err != ErrNoEndpoints {
+		t.Error("Error was not ErrNoEndpoints:", err)
+	}
+}
+
+func Test_PutGetDelete_WithUnavailableEndpoint_RetriesAndFindsLeader(t *testing.T) {
+	client, err := NewClient([]string{deadAddress, "127.0.0.1:" + apiPort})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Put(ctx, "a", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.Leader() != "127.0.0.1:"+apiPort {
+		t.Error("Leader was not cached:", client.Leader())
+	}
+
+	value, err := client.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "A" {
+		t.Error("Value was not A:", value)
+	}
+
+	err = client.Delete(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err = client.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "" {
+		t.Error("Value was not empty:", value)
+	}
+}
+
+func Test_Put_WhenNoEndpointIsAvailable_FailsAtDeadline(t *testing.T) {
+	client, err := NewClient([]string{deadAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = client.Put(ctx, "a", "A")
+	if err == nil {
+		t.Fatal("Put succeeded without a server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Error("Put did not stop at the deadline:", elapsed)
+	}
+}
+
+// failingServer is a KeyValueStore server that fails every request with err,
+// and counts the requests it receives.
+type failingServer struct {
+	err error
+
+	mutex    sync.Mutex
+	requests int
+}
+
+func (s *failingServer) fail() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests++
+	return s.err
+}
+
+func (s *failingServer) Put(ctx context.Context, request *rpc.PutRequest) (*rpc.PutResponse, error) {
+	return nil, s.fail()
+}
+
+func (s *failingServer) Get(ctx context.Context, request *rpc.GetRequest) (*rpc.GetResponse, error) {
+	return nil, s.fail()
+}
+
+func (s *failingServer) Delete(ctx context.Context, request *rpc.DeleteRequest) (*rpc.DeleteResponse, error) {
+	return nil, s.fail()
+}
+
+func (s *failingServer) requestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+// startFailingServer serves a failingServer on a free local port, returning a
+// client connected to it and a function that stops both.
+func startFailingServer(t *testing.T, err error) (*failingServer, *Client, func()) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatal(listenErr)
+	}
+	server := &failingServer{err: err}
+	grpcServer := grpc.NewServer()
+	rpc.RegisterKeyValueStoreServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+
+	client, clientErr := NewClient([]string{listener.Addr().String()})
+	if clientErr != nil {
+		t.Fatal(clientErr)
+	}
+	client.InitialBackoff = 10 * time.Millisecond
+	return server, client, func() {
+		client.Close()
+		grpcServer.Stop()
+	}
+}
+
+func Test_PutAndDelete_WhenOutcomeIsUnknown_ReturnErrorWithoutRetrying(t *testing.T) {
+	for _, code := range []codes.Code{codes.Unavailable, codes.Aborted, codes.DeadlineExceeded} {
+		server, client, stop := startFailingServer(t, status.Error(code, "failed"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := client.Put(ctx, "a", "A")
+		if status.Code(err) != code {
+			t.Errorf("Put did not fail with %v: %v", code, err)
+		}
+		err = client.Delete(ctx, "a")
+		if status.Code(err) != code {
+			t.Errorf("Delete did not fail with %v: %v", code, err)
+		}
+		cancel()
+
+		if server.requestCount() != 2 {
+			t.Errorf("Writes that failed with %v were sent %d times", code, server.requestCount())
+		}
+		stop()
+	}
+}
+
+func Test_Get_WhenOutcomeIsUnknown_Retries(t *testing.T) {
+	server, client, stop := startFailingServer(t, status.Error(codes.Unavailable, "failed"))
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Get(ctx, "a")
+	if err == nil {
+		t.Fatal("Get succeeded")
+	}
+	if server.requestCount() < 2 {
+		t.Error("Get was not retried:", server.requestCount())
+	}
+}
+
+func Test_Put_WhenNodeIsNotLeader_Retries(t *testing.T) {
+	notLeader, err := status.New(codes.FailedPrecondition, "node is not the leader").WithDetails(&rpc.NotLeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client, stop := startFailingServer(t, notLeader.Err())
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	err = client.Put(ctx, "a", "A")
+	if err == nil {
+		t.Fatal("Put succeeded")
+	}
+	if server.requestCount() < 2 {
+		t.Error("Put was not retried:", server.requestCount())
+	}
+}
+
+func Test_jitter_ReturnsBetweenHalfAndAllOfBackoff(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		delay := jitter(100 * time.Millisecond)
+		if delay < 50*time.Millisecond || delay > 100*time.Millisecond {
+			t.Fatal("Delay was not between 50ms and 100ms:", delay)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"time"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// electionResult describes how a round of leader election ended.
+type electionResult int
+
+const (
+	// The node received votes from a majority of the cluster.
+	electionWon electionResult = iota
+
+	// Another node became leader or a newer term was discovered.
+	electionLost
+
+	// Not enough votes were received before the election timed out.
+	electionTimedOut
+)
+
+// runCandidate runs an election for the node's current term, making the node
+// the leader if it wins or starting a new term if the election times out.
+func runCandidate() {
+	term, result := runElection()
+
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	switch result {
+	case electionWon:
+		nodeState.BecomeLeader(term, global.Config.NodeId, nodeState.Membership().NodeIds())
+	case electionTimedOut:
+		// Only start a new term if nothing else changed the role meanwhile.
+		// With pre-votes, the node goes back to being a follower instead, so
+		// that the next term is only started if a quorum would vote for it.
+		if nodeState.Role() != state.Candidate || nodeState.CurrentTerm() != term {
+			return
+		} else if global.Config.PreVote {
+			nodeState.BecomeFollower("")
+		} else {
+			nodeState.BecomeCandidate(global.Config.NodeId)
+		}
+	}
+}
+
+// runElection requests votes for the node's current term from every other
+// voter in the cluster, waiting until it has a quorum, its role changes, or
+// the election times out.
+func runElection() (uint32, electionResult) {
+	nodeState := state.GetNodeState()
+
+	nodeState.Lock()
+	term := nodeState.CurrentTerm()
+	if nodeState.Role() != state.Candidate {
+		nodeState.Unlock()
+		return term, electionLost
+	}
+	roleChanged := nodeState.RoleChanged()
+	membership := nodeState.Membership()
+	request := &rpc.RequestVoteRequest{
+		Term:         term,
+		CandidateId:  global.Config.NodeId,
+		LastLogIndex: nodeState.LogLength(),
+		LastLogTerm:  nodeState.LastLogTerm(),
+
+		LeadershipTransfer: nodeState.TransferElection(),
+	}
+	nodeState.Unlock()
+
+	global.Log.Infof("Starting election for term %d", term)
+	return term, collectVotes(term, request, membership, roleChanged)
+}
+
+// runPreVote asks every other voter whether it would vote for the node in the
+// next term, and makes the node a candidate for that term if a quorum would.
+// Nothing is changed if the node has heard from a leader meanwhile.
+func runPreVote() {
+	nodeState := state.GetNodeState()
+
+	nodeState.Lock()
+	term := nodeState.CurrentTerm()
+	if nodeState.Role() != state.Follower {
+		nodeState.Unlock()
+		return
+	}
+	roleChanged := nodeState.RoleChanged()
+	membership := nodeState.Membership()
+	request := &rpc.RequestVoteRequest{
+		Term:         term + 1,
+		CandidateId:  global.Config.NodeId,
+		LastLogIndex: nodeState.LogLength(),
+		LastLogTerm:  nodeState.LastLogTerm(),
+		PreVote:      true,
+	}
+	nodeState.Unlock()
+
+	global.Log.Infof("Starting pre-vote for term %d", term+1)
+	if collectVotes(term, request, membership, roleChanged) != electionWon {
+		return
+	}
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+	if nodeState.Role() == state.Follower && nodeState.CurrentTerm() == term &&
+		!nodeState.HeardFromLeader(global.MinElectionTimeout()) {
+		nodeState.BecomeCandidate(global.Config.NodeId)
+	}
+}
+
+// collectVotes sends the RequestVote request to every other voter in the
+// membership at the same time, and waits until a quorum has granted its vote,
+// the node's role changes, or the election times out. The term is the node's
+// current term, which it steps down from if a newer one is discovered.
+func collectVotes(term uint32, request *rpc.RequestVoteRequest, membership state.Membership, roleChanged <-chan bool) electionResult {
+	nodeState := state.GetNodeState()
+	kind := "Election"
+	if request.PreVote {
+		kind = "Pre-vote"
+	}
+
+	// The channel is buffered so that responses arriving after the election
+	// ends don't block.
+	nodeIds := membership.VoterIds()
+	responses := make(chan *voteResponse, len(nodeIds))
+	pending := 0
+	for _, nodeId := range nodeIds {
+		if nodeId == global.Config.NodeId {
+			continue
+		}
+		host, _ := membership.Host(nodeId)
+		go requestVote(nodeId, host.RpcAddress(), request, responses)
+		pending++
+	}
+
+	// The node votes for itself. While the membership is joint, the votes
+	// must include a majority of both the old and new nodes.
+	votes := map[string]bool{global.Config.NodeId: true}
+	won := func(nodeId string) bool { return votes[nodeId] }
+
+	timeout := time.After(time.Duration(global.GenerateTimeout(
+		global.Config.ElectionTimeout,
+		global.Config.ElectionTimeoutJitter)) * time.Millisecond)
+	for {
+		if membership.HasQuorum(won) {
+			global.Log.Infof("%s for term %d won with %d votes", kind, request.Term, len(votes))
+			return electionWon
+		}
+
+		// Stop reading responses once every node has replied, but keep waiting
+		// for the timeout or a leader.
+		pendingResponses := responses
+		if pending == 0 {
+			pendingResponses = nil
+		}
+
+		select {
+		case vote := <-pendingResponses:
+			pending--
+			response := vote.response
+			if response == nil {
+				continue
+			}
+			if response.Term > term {
+				nodeState.Lock()
+				nodeState.StepDown(response.Term)
+				nodeState.Unlock()
+				return electionLost
+			}
+			if response.VoteGranted {
+				votes[vote.nodeId] = true
+			}
+		case <-roleChanged:
+			// A leader has been heard from, the node voted for another
+			// candidate in a newer term, or it started a newer election, such
+			// as when told to by TimeoutNow.
+			global.Log.Infof("%s for term %d abandoned", kind, request.Term)
+			return electionLost
+		case <-timeout:
+			global.Log.Infof("%s for term %d timed out with %d votes", kind, request.Term, len(votes))
+			return electionTimedOut
+		}
+	}
+}
+
+// voteResponse is a node's response to a RequestVote request.
+type voteResponse struct {
+	nodeId   string
+	response *rpc.RequestVoteResponse
+}
+
+// requestVote sends the RequestVote request to a single node and passes the
+// response to the responses channel, with a nil response if the request
+// failed.
+func requestVote(nodeId string, address string, request *rpc.RequestVoteRequest, responses chan<- *voteResponse) {
+	response, err := rpc.SendRequestVote(address, request)
+	if err != nil {
+		global.Log.Debugf("RequestVote to %s failed: %v", nodeId, err)
+		response = nil
+	}
+	responses <- &voteResponse{nodeId: nodeId, response: response}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// The election timeout used by the tests, in milliseconds.
+const testElectionTimeout uint32 = 200
+
+// setUpElection configures node 1 with a short election timeout, restoring
+// the configuration when the returned function is called.
+func setUpElection(preVote bool) func() {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+	global.Config.NodeId = "1"
+	global.Config.ElectionTimeout = testElectionTimeout
+	global.Config.ElectionTimeoutJitter = 0
+	global.Config.PreVote = preVote
+	return func() {
+		global.Config.ElectionTimeout = 0
+		global.Config.PreVote = false
+	}
+}
+
+// startVoters serves a testFollower for each node id other than node 1, which
+// answers RequestVote requests with vote. It returns the hosts of every node,
+// including node 1, and a function that stops the voters once each of them has
+// answered a request, so that no vote is still being sent to a stopped voter.
+func startVoters(t *testing.T, nodeIds []string, vote func(nodeId string, request *rpc.RequestVoteRequest) *rpc.RequestVoteResponse) (map[string]global.NodeHost, func()) {
+	hosts := map[string]global.NodeHost{"1": {Url: "127.0.0.1"}}
+	var servers []*grpc.Server
+	var addresses []string
+	var answered sync.WaitGroup
+	for _, nodeId := range nodeIds {
+		if _, ok := hosts[nodeId]; ok {
+			continue
+		}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodeId := nodeId
+		var once sync.Once
+		answered.Add(1)
+		server := grpc.NewServer()
+		rpc.RegisterGoRaftServer(server, &testFollower{
+			vote: func(request *rpc.RequestVoteRequest) *rpc.RequestVoteResponse {
+				defer once.Do(answered.Done)
+				return vote(nodeId, request)
+			},
+		})
+		go server.Serve(listener)
+		servers = append(servers, server)
+
+		address := listener.Addr().String()
+		addresses = append(addresses, address)
+		url, port, _ := net.SplitHostPort(address)
+		rpcPort, _ := strconv.Atoi(port)
+		hosts[nodeId] = global.NodeHost{Url: url, RpcPort: uint32(rpcPort)}
+	}
+	return hosts, func() {
+		answered.Wait()
+		for i, server := range servers {
+			server.GracefulStop()
+			rpc.Pool.Remove(addresses[i])
+		}
+	}
+}
+
+// answerVotes returns a vote function for voters in the given term, which
+// grant their votes if they are in granted and refuse them otherwise.
+func answerVotes(term uint32, granted ...string) func(nodeId string, request *rpc.RequestVoteRequest) *rpc.RequestVoteResponse {
+	return func(nodeId string, request *rpc.RequestVoteRequest) *rpc.RequestVoteResponse {
+		for _, grantedId := range granted {
+			if nodeId == grantedId {
+				return &rpc.RequestVoteResponse{Term: term, VoteGranted: true}
+			}
+		}
+		return &rpc.RequestVoteResponse{Term: term}
+	}
+}
+
+// createElectionState returns the state of node 1 in a cluster of the given
+// hosts, which becomes the node's state. The node is a candidate in term 1 if
+// candidate is true, and a follower in term 0 otherwise.
+func createElectionState(hosts map[string]global.NodeHost, candidate bool) *state.NodeState {
+	nodeState := state.NewNodeState(
+		state.NewMemoryDataStore(),
+		state.NewMemoryDataStore(),
+		state.NewMemorySnapshotStore())
+	nodeState.Bootstrap(hosts)
+	if candidate {
+		nodeState.BecomeCandidate("1")
+	}
+	state.Node = nodeState
+	return nodeState
+}
+
+// subset returns the hosts of the given node ids.
+func subset(hosts map[string]global.NodeHost, nodeIds ...string) map[string]global.NodeHost {
+	nodes := make(map[string]global.NodeHost, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		nodes[nodeId] = hosts[nodeId]
+	}
+	return nodes
+}
+
+func Test_collectVotes_CountsVotesAgainstQuorum(t *testing.T) {
+	defer setUpElection(false)()
+
+	var tests = []struct {
+		name     string
+		nodes    []string
+		newNodes []string
+		granted  []string
+		want     electionResult
+	}{
+		{
+			name:    "a majority of votes wins",
+			nodes:   []string{"1", "2", "3"},
+			granted: []string{"2"},
+			want:    electionWon,
+		},
+		{
+			name:    "a minority of votes times out",
+			nodes:   []string{"1", "2", "3", "4", "5"},
+			granted: []string{"2"},
+			want:    electionTimedOut,
+		},
+		{
+			name:     "a joint membership wins with a majority of both sets",
+			nodes:    []string{"1", "2", "3"},
+			newNodes: []string{"1", "4", "5"},
+			granted:  []string{"2", "4"},
+			want:     electionWon,
+		},
+		{
+			name:     "a joint membership times out with a majority of only the old nodes",
+			nodes:    []string{"1", "2", "3"},
+			newNodes: []string{"4", "5", "6"},
+			granted:  []string{"2", "3", "4"},
+			want:     electionTimedOut,
+		},
+		{
+			name:     "a joint membership times out with a majority of only the new nodes",
+			nodes:    []string{"1", "2", "3"},
+			newNodes: []string{"4", "5", "6"},
+			granted:  []string{"4", "5", "6"},
+			want:     electionTimedOut,
+		},
+	}
+
+	for _, test := range tests {
+		hosts, stop := startVoters(t, append(test.nodes, test.newNodes...), answerVotes(1, test.granted...))
+		nodeState := createElectionState(subset(hosts, test.nodes...), true)
+		membership := state.Membership{Nodes: subset(hosts, test.nodes...)}
+		if test.newNodes != nil {
+			membership.NewNodes = subset(hosts, test.newNodes...)
+		}
+
+		request := &rpc.RequestVoteRequest{Term: 1, CandidateId: "1"}
+		result := collectVotes(1, request, membership, nodeState.RoleChanged())
+		stop()
+		if result != test.want {
+			t.Errorf("%s: result was %d instead of %d", test.name, result, test.want)
+		}
+	}
+}
+
+func Test_collectVotes_WhenResponseHasHigherTerm_StepsDown(t *testing.T) {
+	defer setUpElection(false)()
+
+	hosts, stop := startVoters(t, []string{"2", "3"}, answerVotes(5))
+	defer stop()
+	nodeState := createElectionState(hosts, true)
+
+	request := &rpc.RequestVoteRequest{Term: 1, CandidateId: "1"}
+	result := collectVotes(1, request, nodeState.Membership(), nodeState.RoleChanged())
+	if result != electionLost {
+		t.Error("Election was not lost:", result)
+	}
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+	if nodeState.Role() != state.Follower || nodeState.CurrentTerm() != 5 {
+		t.Error("Node did not step down to term 5:", nodeState.Role(), nodeState.CurrentTerm())
+	}
+}
+
+func Test_runCandidate_WhenElectionTimesOut(t *testing.T) {
+	var tests = []struct {
+		name     string
+		preVote  bool
+		wantRole state.Role
+		wantTerm uint32
+	}{
+		{
+			name:     "without pre-votes, a new term is started",
+			wantRole: state.Candidate,
+			wantTerm: 2,
+		},
+		{
+			name:     "with pre-votes, the node becomes a follower",
+			preVote:  true,
+			wantRole: state.Follower,
+			wantTerm: 1,
+		},
+	}
+
+	for _, test := range tests {
+		resetConfig := setUpElection(test.preVote)
+		hosts, stop := startVoters(t, []string{"2", "3"}, answerVotes(1))
+		nodeState := createElectionState(hosts, true)
+
+		runCandidate()
+		stop()
+		resetConfig()
+
+		nodeState.Lock()
+		if nodeState.Role() != test.wantRole || nodeState.CurrentTerm() != test.wantTerm {
+			t.Errorf("%s: node was %v in term %d", test.name, nodeState.Role(), nodeState.CurrentTerm())
+		}
+		nodeState.Unlock()
+	}
+}
+
+func Test_runCandidate_WhenElectionIsWon_BecomesLeader(t *testing.T) {
+	defer setUpElection(false)()
+
+	hosts, stop := startVoters(t, []string{"2", "3"}, answerVotes(1, "2"))
+	defer stop()
+	nodeState := createElectionState(hosts, true)
+
+	runCandidate()
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+	if nodeState.Role() != state.Leader || nodeState.CurrentTerm() != 1 {
+		t.Error("Node did not become leader in term 1:", nodeState.Role(), nodeState.CurrentTerm())
+	}
+}
+
+func Test_runPreVote_WhenQuorumWouldNotVote_KeepsTerm(t *testing.T) {
+	defer setUpElection(true)()
+
+	// The voters check that the pre-vote is for the next term without the
+	// node having moved to it.
+	var requestTerms, currentTerms []uint32
+	hosts, stop := startVoters(t, []string{"2", "3"}, func(nodeId string, request *rpc.RequestVoteRequest) *rpc.RequestVoteResponse {
+		state.Node.Lock()
+		defer state.Node.Unlock()
+		if !request.PreVote {
+			t.Error("Request was not a pre-vote")
+		}
+		requestTerms = append(requestTerms, request.Term)
+		currentTerms = append(currentTerms, state.Node.CurrentTerm())
+		return &rpc.RequestVoteResponse{Term: 0}
+	})
+	defer stop()
+	nodeState := createElectionState(hosts, false)
+
+	runPreVote()
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+	if nodeState.Role() != state.Follower || nodeState.CurrentTerm() != 0 {
+		t.Error("Node left term 0:", nodeState.Role(), nodeState.CurrentTerm())
+	}
+	if len(requestTerms) != 2 {
+		t.Fatal("Voters did not receive pre-votes:", len(requestTerms))
+	}
+	for i := range requestTerms {
+		if requestTerms[i] != 1 || currentTerms[i] != 0 {
+			t.Errorf("Pre-vote was for term %d while the node was in term %d", requestTerms[i], currentTerms[i])
+		}
+	}
+}
+
+func Test_runPreVote_WhenQuorumWouldVote_BecomesCandidateInNextTerm(t *testing.T) {
+	defer setUpElection(true)()
+
+	hosts, stop := startVoters(t, []string{"2", "3"}, answerVotes(0, "2"))
+	defer stop()
+	nodeState := createElectionState(hosts, false)
+
+	runPreVote()
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+	if nodeState.Role() != state.Candidate || nodeState.CurrentTerm() != 1 {
+		t.Error("Node did not become a candidate in term 1:", nodeState.Role(), nodeState.CurrentTerm())
+	}
+}
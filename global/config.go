@@ -2,6 +2,7 @@ package global
 
 import (
 	"io/ioutil"
+	"strconv"
 
 	"github.com/go-yaml/yaml"
 )
@@ -13,14 +14,44 @@ type NodeHost struct {
 	RpcPort uint32 `yaml:"rpc_port"`
 }
 
+// ApiAddress returns the host:port address that the node's client API
+// listens on.
+func (host NodeHost) ApiAddress() string {
+	return host.Url + ":" + strconv.Itoa(int(host.ApiPort))
+}
+
+// RpcAddress returns the host:port address that the node listens on for RPCs
+// from other nodes in the cluster.
+func (host NodeHost) RpcAddress() string {
+	return host.Url + ":" + strconv.Itoa(int(host.RpcPort))
+}
+
+// MaxEntriesPerRequest is the maximum number of log entries sent in a single
+// AppendEntries request.
+const MaxEntriesPerRequest int = 100
+
 // ConfigMap contains all the configurations loaded from the config file.
 type ConfigMap struct {
-	LogLevel              string              `yaml:"log_level"`
-	ElectionTimeout       uint32              `yaml:"election_timeout"`
-	ElectionTimeoutJitter uint32              `yaml:"election_timeout_jitter"`
-	LeaderHeartbeatPeriod uint32              `yaml:"leader_heartbeat_period"`
-	NodeId                string              `yaml:"node_id"`
-	Nodes                 map[string]NodeHost `yaml:"node_hosts"`
+	LogLevel                string              `yaml:"log_level"`
+	ElectionTimeout         uint32              `yaml:"election_timeout"`
+	ElectionTimeoutJitter   uint32              `yaml:"election_timeout_jitter"`
+	LeaderHeartbeatPeriod   uint32              `yaml:"leader_heartbeat_period"`
+	RpcTimeout              uint32              `yaml:"rpc_timeout"`
+	NodeId                  string              `yaml:"node_id"`
+	Nodes                   map[string]NodeHost `yaml:"node_hosts"`
+	ForwardToLeader         bool                `yaml:"forward_to_leader"`
+	SnapshotThreshold       uint32              `yaml:"snapshot_threshold"`
+	SnapshotTrailingEntries uint32              `yaml:"snapshot_trailing_entries"`
+	JoinCluster             bool                `yaml:"join_cluster"`
+	PreVote                 bool                `yaml:"pre_vote"`
+	MaxInflightRequests     uint32              `yaml:"max_inflight_requests"`
+	MaxInflightBytes        uint32              `yaml:"max_inflight_bytes"`
+	StreamAppendEntries     bool                `yaml:"stream_append_entries"`
+	ProposalBatchWindow     uint32              `yaml:"proposal_batch_window"`
+	ProposalBatchMaxEntries uint32              `yaml:"proposal_batch_max_entries"`
+	ProposalBatchMaxBytes   uint32              `yaml:"proposal_batch_max_bytes"`
+	LeaseReads              bool                `yaml:"lease_reads"`
+	LeaseClockDrift         *uint32             `yaml:"lease_clock_drift"`
 }
 
 // Config contains the loaded configurations.
@@ -38,5 +69,19 @@ func LoadConfig() ConfigMap {
 		Log.Panic(err)
 	}
 
+	// Election timeouts are generated between the average minus the jitter
+	// and the average plus the jitter, so the jitter must be smaller.
+	if Config.ElectionTimeoutJitter >= Config.ElectionTimeout {
+		Log.Panicf("election_timeout_jitter (%d) must be less than election_timeout (%d)",
+			Config.ElectionTimeoutJitter, Config.ElectionTimeout)
+	}
+
+	// A batch of proposals must fit in a single AppendEntries request so that
+	// it reaches the followers together.
+	if int(Config.ProposalBatchMaxEntries) > MaxEntriesPerRequest {
+		Log.Panicf("proposal_batch_max_entries (%d) must be at most %d",
+			Config.ProposalBatchMaxEntries, MaxEntriesPerRequest)
+	}
+
 	return Config
 }
@@ -2,11 +2,12 @@ package global
 
 import (
 	"math/rand"
+	"time"
 )
 
 // TimeoutChannel is the channel used for kicking off leader election when
 // a node has not received a leader heartbeat in a while.
-var TimeoutChannel chan bool = make(chan bool)
+var TimeoutChannel chan bool = make(chan bool, 1)
 
 // GenerateTimeout returns a timeout value between average - jitter and
 // average + jitter.
@@ -17,3 +18,39 @@ func GenerateTimeout(average uint32, jitter uint32) uint32 {
 
 	return average - jitter + uint32(rand.Intn(2*int(jitter)))
 }
+
+// MinElectionTimeout returns the shortest election timeout that any node can
+// generate from the configured average and jitter.
+func MinElectionTimeout() time.Duration {
+	return time.Duration(Config.ElectionTimeout-Config.ElectionTimeoutJitter) * time.Millisecond
+}
+
+// defaultLeaseClockDrift is the clock drift allowed for when lease_clock_drift
+// isn't set, which is told apart from a drift of 0.
+const defaultLeaseClockDrift uint32 = 10
+
+// LeaseDuration returns how long after sending requests that a quorum
+// responded to the leader can serve reads without contacting the other nodes.
+// It is the minimum election timeout less the configured clock drift, and is
+// zero if the drift is at least the minimum election timeout.
+func LeaseDuration() time.Duration {
+	drift := defaultLeaseClockDrift
+	if Config.LeaseClockDrift != nil {
+		drift = *Config.LeaseClockDrift
+	}
+	duration := MinElectionTimeout() - time.Duration(drift)*time.Millisecond
+	if duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// ResetTimeout notifies the node loop through TimeoutChannel that a valid
+// leader or candidate has been heard from. The notification is dropped if one
+// is already pending, so callers never block.
+func ResetTimeout() {
+	select {
+	case TimeoutChannel <- true:
+	default:
+	}
+}
@@ -21,26 +21,203 @@ func main() {
 	global.SetLogLevel(global.Config.LogLevel)
 
 	// Check if state was loaded correctly from previous run.
-	global.Log.Debug(state.GetNodeState())
+	nodeState := state.GetNodeState()
+	global.Log.Debug(nodeState)
+
+	// A node joining an existing cluster learns the membership from the
+	// leader, while the nodes of a new cluster start with the configured
+	// nodes.
+	if !global.Config.JoinCluster {
+		nodeState.Lock()
+		nodeState.Bootstrap(global.Config.Nodes)
+		nodeState.Unlock()
+	}
 
 	runNode()
 }
 
-// runNode runs the infinite loop that keeps the node active.
+// runNode runs the infinite loop that keeps the node active, acting according
+// to the node's current role.
 func runNode() {
-	go rpc.RunServer(strconv.Itoa(int(global.Config.Nodes[global.Config.NodeId].ApiPort)))
+	// Peer RPCs and client requests are served on separate ports so that
+	// client load can't delay heartbeats and the ports can be firewalled
+	// separately.
+	localHost := global.Config.Nodes[global.Config.NodeId]
+	go rpc.RunRpcServer(strconv.Itoa(int(localHost.RpcPort)))
+	go rpc.RunApiServer(strconv.Itoa(int(localHost.ApiPort)))
+	go runApplier()
+	go closeRemovedConnections()
+
+	nodeState := state.GetNodeState()
+	for {
+		nodeState.Lock()
+		role := nodeState.Role()
+		nodeState.Unlock()
+
+		switch role {
+		case state.Follower:
+			runFollower()
+		case state.Candidate:
+			runCandidate()
+		case state.Leader:
+			runLeader()
+		}
+	}
+}
 
+// runFollower waits for a message from the leader or a candidate, and makes
+// the node a candidate if none arrives before the election timeout.
+func runFollower() {
 	// Randomize the election timeout to minimize the risk of two nodes
 	// initiating an election at the same time.
-	electionTimeout := global.Config.ElectionTimeout
-	electionTimeoutJitter := global.Config.ElectionTimeoutJitter
+	timeout := global.GenerateTimeout(global.Config.ElectionTimeout, global.Config.ElectionTimeoutJitter)
+	select {
+	case <-global.TimeoutChannel:
+		// Do nothing since we didn't time out.
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		nodeState := state.GetNodeState()
+		nodeState.Lock()
+		// Nodes that can't vote, such as learners and nodes that are still
+		// joining or have been removed, never start elections.
+		if !nodeState.Membership().IsVoter(global.Config.NodeId) {
+			nodeState.Unlock()
+			return
+		} else if global.Config.PreVote {
+			nodeState.Unlock()
+			runPreVote()
+			return
+		}
+		nodeState.BecomeCandidate(global.Config.NodeId)
+		nodeState.Unlock()
+	}
+}
+
+// runLeader keeps the node acting as leader, replicating its log to every
+// other node in the membership, until its role changes.
+func runLeader() {
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	if nodeState.Role() != state.Leader {
+		nodeState.Unlock()
+		return
+	}
+	term := nodeState.CurrentTerm()
+	roleChanged := nodeState.RoleChanged()
+
+	// Entries from earlier terms can only be committed along with an entry
+	// from the current term, so start the term with an empty entry that is
+	// not applied to the storage state.
+	err := nodeState.SetLogEntry(nodeState.LogLength()+1, state.LogEntry{Term: term})
+	if err != nil {
+		global.Log.Error("Failed to append no-op entry:", err.Error())
+	}
+	nodeState.AdvanceCommitIndex(global.Config.NodeId)
+	nodeState.Unlock()
+
+	// The leader steps down if it doesn't hear from a quorum within an
+	// election timeout, since the other nodes may have elected a new leader.
+	electionTimeout := time.Duration(global.Config.ElectionTimeout) * time.Millisecond
+	checkQuorum := time.NewTicker(electionTimeout)
+	defer checkQuorum.Stop()
+
+	// Each node being replicated to has its own channel to stop replication.
+	peers := make(map[string]chan bool)
+	addresses := make(map[string]string)
+	defer func() {
+		for _, stop := range peers {
+			close(stop)
+		}
+	}()
+
+	for {
+		nodeState.Lock()
+		membership := nodeState.Membership()
+		committed := nodeState.MembershipCommitted()
+		membershipChanged := nodeState.MembershipChanged()
+		commitIndexChanged := nodeState.CommitIndexChanged()
+		nodeState.Unlock()
+
+		for _, nodeId := range membership.NodeIds() {
+			if _, ok := peers[nodeId]; ok || nodeId == global.Config.NodeId {
+				continue
+			}
+			host, _ := membership.Host(nodeId)
+			peers[nodeId] = make(chan bool)
+			addresses[nodeId] = host.RpcAddress()
+			go replicate(nodeId, addresses[nodeId], term, peers[nodeId])
+		}
+
+		// Removed nodes need to receive the entry that removes them, so
+		// replication to them only stops once that entry is committed. The
+		// RPC connection to a removed node is closed, since nothing else uses
+		// it.
+		if committed {
+			for nodeId, stop := range peers {
+				if !membership.Contains(nodeId) {
+					close(stop)
+					delete(peers, nodeId)
+					rpc.Pool.Remove(addresses[nodeId])
+					delete(addresses, nodeId)
+				}
+			}
+			commitIndexChanged = nil
+		}
+
+		select {
+		case <-roleChanged:
+			return
+		case <-membershipChanged:
+		case <-commitIndexChanged:
+		case <-checkQuorum.C:
+			nodeState.Lock()
+			nodeState.CheckQuorum(global.Config.NodeId, electionTimeout)
+			nodeState.Unlock()
+		}
+	}
+}
+
+// closeRemovedConnections closes the pooled connections to the client API of
+// nodes once their removal from the membership is committed, since requests
+// forwarded to a node while it was leader leave a connection that would
+// otherwise keep reconnecting to it.
+func closeRemovedConnections() {
+	nodeState := state.GetNodeState()
+	hosts := make(map[string]global.NodeHost)
 	for {
-		timeout := global.GenerateTimeout(electionTimeout, electionTimeoutJitter)
+		nodeState.Lock()
+		membership := nodeState.Membership()
+		committed := nodeState.MembershipCommitted()
+		membershipChanged := nodeState.MembershipChanged()
+		commitIndexChanged := nodeState.CommitIndexChanged()
+		nodeState.Unlock()
+
+		closeRemovedApiConnections(hosts, membership, committed)
+		if committed {
+			commitIndexChanged = nil
+		}
+
 		select {
-		case <-global.TimeoutChannel:
-			// Do nothing since we didn't time out.
-		case <-time.After(time.Duration(timeout) * time.Millisecond):
-			// TODO: Start leader election process.
+		case <-membershipChanged:
+		case <-commitIndexChanged:
+		}
+	}
+}
+
+// closeRemovedApiConnections records the hosts of the nodes in the membership,
+// and if the membership is committed, closes the pooled connections to the
+// client API of the recorded nodes that are no longer in it.
+func closeRemovedApiConnections(hosts map[string]global.NodeHost, membership state.Membership, committed bool) {
+	for _, nodeId := range membership.NodeIds() {
+		hosts[nodeId], _ = membership.Host(nodeId)
+	}
+	if !committed {
+		return
+	}
+
+	for nodeId, host := range hosts {
+		if !membership.Contains(nodeId) {
+			rpc.Pool.Remove(host.ApiAddress())
+			delete(hosts, nodeId)
 		}
 	}
 }
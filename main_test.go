@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+func Test_closeRemovedApiConnections_WhenRemovalIsCommitted_ClosesConnection(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node2 := global.NodeHost{Url: "127.0.0.1", ApiPort: 8102, RpcPort: 9102}
+	hosts := make(map[string]global.NodeHost)
+	closeRemovedApiConnections(hosts, state.Membership{Nodes: map[string]global.NodeHost{"1": {}, "2": node2}}, true)
+
+	// A request was forwarded to node 2 while it was leader.
+	conn, err := rpc.Pool.Get(node2.ApiAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection is kept until the removal is committed.
+	removed := state.Membership{Nodes: map[string]global.NodeHost{"1": {}}}
+	closeRemovedApiConnections(hosts, removed, false)
+	if conn.GetState() == connectivity.Shutdown {
+		t.Fatal("Connection was closed before the removal was committed")
+	}
+
+	closeRemovedApiConnections(hosts, removed, true)
+	if conn.GetState() != connectivity.Shutdown {
+		t.Error("Connection to the removed node was not closed:", conn.GetState())
+	}
+	if _, ok := hosts["2"]; ok {
+		t.Error("Removed node was still recorded")
+	}
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// The number of AppendEntries requests and bytes of entries that can be in
+// flight to a single follower when max_inflight_requests and
+// max_inflight_bytes aren't configured.
+const (
+	defaultMaxInflightRequests int = 8
+	defaultMaxInflightBytes    int = 4 << 20
+)
+
+// The approximate number of bytes that each entry adds to a request besides
+// its key and value.
+const entryOverhead int = 16
+
+// maxInflightRequests returns the number of AppendEntries requests that can be
+// in flight to a single follower.
+func maxInflightRequests() int {
+	if global.Config.MaxInflightRequests == 0 {
+		return defaultMaxInflightRequests
+	}
+	return int(global.Config.MaxInflightRequests)
+}
+
+// maxInflightBytes returns the number of bytes of entries that can be in
+// flight to a single follower, although a single request may exceed it.
+func maxInflightBytes() int {
+	if global.Config.MaxInflightBytes == 0 {
+		return defaultMaxInflightBytes
+	}
+	return int(global.Config.MaxInflightBytes)
+}
+
+// appendResult is the outcome of an AppendEntries request sent by a pipeline.
+type appendResult struct {
+	request    *rpc.AppendEntriesRequest
+	response   *rpc.AppendEntriesResponse
+	err        error
+	size       int
+	generation uint32
+
+	// The read round that the follower's response confirms, and when the
+	// request was sent, which the response extends the leader's lease from.
+	readRound uint32
+	sent      time.Time
+
+	// The stream the request was sent over, or nil if it was sent with the
+	// unary RPC.
+	stream *appendStream
+}
+
+// pipeline sends AppendEntries requests to a single follower. In probing mode,
+// which is used until the follower's log is known to match the leader's, one
+// request is sent at a time. Once a request succeeds, NextIndex is advanced
+// as each request is sent, and requests are sent without waiting for the
+// earlier ones to be answered, up to the inflight limits.
+//
+// If stream_append_entries is enabled, requests are sent over a long-lived
+// stream, and the unary RPC is used while the stream can't be opened or if the
+// follower doesn't support streams.
+type pipeline struct {
+	nodeId  string
+	address string
+	term    uint32
+
+	probing       bool
+	failed        bool
+	inflight      int
+	inflightBytes int
+
+	// generation is incremented whenever NextIndex is reset, so that
+	// responses to requests sent before then don't reset it again.
+	generation uint32
+
+	lastSent time.Time
+	results  chan appendResult
+
+	// Closed when replication to the follower stops, so that requests still
+	// in flight don't wait to report their results.
+	stop <-chan bool
+
+	// The newest read round sent to the follower.
+	readRound uint32
+
+	useStream bool
+	stream    *appendStream
+}
+
+// replicate sends AppendEntries requests to a single follower for as long as
+// the node is leader for the given term. Entries are sent as fast as the
+// inflight limits allow, and once the follower has caught up, heartbeats are
+// sent every LeaderHeartbeatPeriod until new entries are appended.
+func replicate(nodeId string, address string, term uint32, stop <-chan bool) {
+	heartbeatPeriod := time.Duration(global.Config.LeaderHeartbeatPeriod) * time.Millisecond
+	p := &pipeline{
+		nodeId:    nodeId,
+		address:   address,
+		term:      term,
+		probing:   true,
+		results:   make(chan appendResult, maxInflightRequests()),
+		stop:      stop,
+		useStream: global.Config.StreamAppendEntries,
+	}
+	defer p.closeStream()
+
+	heartbeatDue := false
+	for {
+		// After a failure, the stream is only reopened with the next heartbeat.
+		if p.useStream && p.stream == nil && (!p.failed || heartbeatDue) {
+			p.openStream()
+		}
+
+		entriesAppended, readRequested, needsSnapshot := p.send(heartbeatDue)
+		heartbeatDue = false
+		if needsSnapshot {
+			var sent bool
+			entriesAppended, sent = sendInstallSnapshot(nodeId, address, term)
+			// The snapshot takes the place of a heartbeat, and like a failed
+			// request, one that couldn't be sent is only retried with the
+			// next heartbeat.
+			p.lastSent = time.Now()
+			if !sent {
+				p.failed = true
+			} else if entriesAppended == nil {
+				select {
+				case <-stop:
+					return
+				default:
+					continue
+				}
+			}
+		}
+
+		// Requests in flight already let the follower know that the leader is
+		// alive, and a heartbeat sent alongside them could arrive first and be
+		// rejected.
+		var heartbeat <-chan time.Time
+		if p.inflight == 0 {
+			heartbeat = time.After(heartbeatPeriod - time.Since(p.lastSent))
+		}
+
+		select {
+		case <-stop:
+			return
+		case result := <-p.results:
+			p.receive(result)
+		case <-entriesAppended:
+		case <-readRequested:
+		case <-heartbeat:
+			heartbeatDue = true
+		}
+	}
+}
+
+// send sends the follower the requests returned by nextRequests.
+//
+// It returns channels that are closed when new entries are appended and when
+// a read round is started, and true if the follower needs a snapshot, which is
+// only sent once there are no requests in flight.
+func (p *pipeline) send(heartbeat bool) (<-chan bool, <-chan bool, bool) {
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	results, needsSnapshot := p.nextRequests(nodeState, heartbeat)
+	for _, result := range results {
+		p.transmit(result)
+	}
+	return nodeState.EntriesAppended(), nodeState.ReadRequested(), needsSnapshot
+}
+
+// nextRequests returns the requests with the entries starting at the
+// follower's NextIndex, until there are no more entries to send or the
+// inflight limits are reached, and counts them as in flight. If heartbeat is
+// true, a request is returned even if there are no entries to send.
+//
+// A heartbeat is also sent if a read round has started since the last request
+// and none are in flight, so that reads don't wait for the heartbeat period.
+//
+// It returns true if the follower needs a snapshot and no requests are in
+// flight. The node state lock must be held.
+func (p *pipeline) nextRequests(nodeState *state.NodeState, heartbeat bool) ([]appendResult, bool) {
+	// Wait for the next heartbeat before trying again after a failure.
+	if heartbeat {
+		p.failed = false
+	} else if p.failed {
+		return nil, false
+	}
+	if p.inflight == 0 && nodeState.ReadRound() > p.readRound {
+		heartbeat = true
+	}
+
+	var results []appendResult
+	for p.inflight < maxInflightRequests() && p.inflightBytes < maxInflightBytes() && !(p.probing && p.inflight > 0) {
+		if nodeState.Role() != state.Leader || nodeState.CurrentTerm() != p.term {
+			break
+		}
+		nextIndex := nodeState.NextIndex[p.nodeId]
+		if nextIndex <= nodeState.LogOffset() {
+			// The entries the follower needs have been replaced by a snapshot.
+			return results, p.inflight == 0
+		} else if nextIndex > nodeState.LogLength() && !heartbeat {
+			break
+		}
+
+		request := &rpc.AppendEntriesRequest{
+			Term:         p.term,
+			LeaderId:     global.Config.NodeId,
+			PrevLogIndex: nextIndex - 1,
+			PrevLogTerm:  nodeState.LogTerm(nextIndex - 1),
+			Entries:      []*rpc.AppendEntriesRequest_Entry{},
+			LeaderCommit: nodeState.CommitIndex,
+		}
+		size := 0
+		for i := nextIndex; i <= nodeState.LogLength() && len(request.Entries) < global.MaxEntriesPerRequest; i++ {
+			entry := nodeState.Log(i)
+			entrySize := len(entry.Key) + len(entry.Value) + entryOverhead
+			if len(request.Entries) > 0 && p.inflightBytes+size+entrySize > maxInflightBytes() {
+				break
+			}
+			request.Entries = append(request.Entries, &rpc.AppendEntriesRequest_Entry{
+				Key:   entry.Key,
+				Value: entry.Value,
+				Term:  entry.Term,
+			})
+			size += entrySize
+		}
+
+		// While probing, NextIndex is only advanced once the follower accepts
+		// the entries.
+		if !p.probing {
+			nodeState.NextIndex[p.nodeId] = nextIndex + uint32(len(request.Entries))
+		}
+
+		p.inflight++
+		p.inflightBytes += size
+		p.lastSent = time.Now()
+		p.readRound = nodeState.ReadRoundForRequest()
+		results = append(results, appendResult{request: request, size: size, generation: p.generation, readRound: p.readRound, sent: p.lastSent})
+
+		heartbeat = false
+	}
+	return results, false
+}
+
+// transmit sends the request over the pipeline's stream, or with the unary RPC
+// if there is no stream, and reports the outcome to the results channel.
+func (p *pipeline) transmit(result appendResult) {
+	if p.stream != nil {
+		p.stream.send(result)
+		return
+	}
+	go func() {
+		result.response, result.err = rpc.SendAppendEntries(p.address, result.request)
+		select {
+		case p.results <- result:
+		case <-p.stop:
+		}
+	}()
+}
+
+// receive handles the outcome of a request with update, after closing the
+// stream it was sent over if the stream failed.
+func (p *pipeline) receive(result appendResult) {
+	if result.stream != nil {
+		if result.stream != p.stream {
+			// The requests of a stream stop being counted when it fails.
+			return
+		}
+		result.stream.inflight--
+		result.stream.inflightBytes -= result.size
+		if result.err != nil {
+			// The other requests sent over the stream won't be answered.
+			p.closeStream()
+			if status.Code(result.err) == codes.Unimplemented {
+				global.Log.Infof("%s doesn't support AppendEntries streams, so unary requests will be used", p.nodeId)
+				p.useStream = false
+			}
+		}
+	}
+
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	if p.update(nodeState, result) {
+		go sendTimeoutNow(p.nodeId, p.address, p.term)
+	}
+}
+
+// update updates NextIndex and MatchIndex based on the follower's response to
+// a request. A failed or rejected request puts the pipeline back into probing
+// mode. Requests that arrive at the follower out of order are rejected, since
+// the follower is missing the entries before them, so they are handled the
+// same way. Failures and rejections of requests sent before the pipeline was
+// last reset are ignored.
+//
+// It returns true if the follower is the target of a leadership transfer and
+// should be told to start an election. The node state lock must be held.
+func (p *pipeline) update(nodeState *state.NodeState, result appendResult) bool {
+	p.inflight--
+	p.inflightBytes -= result.size
+
+	if result.err != nil {
+		global.Log.Debugf("AppendEntries to %s failed: %v", p.nodeId, result.err)
+		p.failed = true
+		if result.generation == p.generation && !p.probing {
+			// The entries sent after the last ones known to match may have
+			// been lost, so start again from there.
+			nodeState.NextIndex[p.nodeId] = nodeState.MatchIndex[p.nodeId] + 1
+			p.reset()
+		}
+		return false
+	}
+	response := result.response
+
+	// Step down if the follower knows of a newer term, and ignore responses
+	// that arrive after the node stopped being leader for the term.
+	if nodeState.StepDown(response.Term) || nodeState.Role() != state.Leader || nodeState.CurrentTerm() != p.term {
+		return false
+	}
+	nodeState.LastContact[p.nodeId] = time.Now()
+
+	// Any response in the leader's term, even a rejection, shows that the
+	// follower hadn't moved on to a newer leader when the request arrived.
+	nodeState.AckReadRound(p.nodeId, result.readRound, global.Config.NodeId)
+	nodeState.AckLease(p.nodeId, result.sent)
+
+	if response.Success {
+		// A success is valid even if NextIndex has been reset since the
+		// request was sent, because the leader's entries in its own term never
+		// change.
+		matchIndex := result.request.PrevLogIndex + uint32(len(result.request.Entries))
+		if matchIndex > nodeState.MatchIndex[p.nodeId] {
+			nodeState.MatchIndex[p.nodeId] = matchIndex
+		}
+		if nodeState.NextIndex[p.nodeId] <= nodeState.MatchIndex[p.nodeId] {
+			nodeState.NextIndex[p.nodeId] = nodeState.MatchIndex[p.nodeId] + 1
+		}
+		if result.generation == p.generation {
+			p.probing = false
+		}
+
+		// The new CommitIndex is passed to the followers in the next
+		// AppendEntries requests.
+		nodeState.AdvanceCommitIndex(global.Config.NodeId)
+
+		// Once the target of a leadership transfer has caught up, it is told
+		// to start an election.
+		return nodeState.TimeoutNowReady(p.nodeId)
+	} else if result.generation == p.generation {
+		// The follower's log doesn't contain the entry at PrevLogIndex, so try
+		// again further back in the log.
+		nextIndex := result.request.PrevLogIndex + 1
+		nodeState.NextIndex[p.nodeId] = backtrackNextIndex(nodeState, p.nodeId, nextIndex, response)
+		global.Log.Debugf("Backed up NextIndex for %s to %d", p.nodeId, nodeState.NextIndex[p.nodeId])
+		p.reset()
+	}
+	return false
+}
+
+// openStream opens a stream to the follower, leaving the pipeline to use the
+// unary RPC if it can't be opened.
+func (p *pipeline) openStream() {
+	stream, err := openAppendStream(p.address, p.results)
+	if err != nil {
+		global.Log.Debugf("Failed to open AppendEntries stream to %s: %v", p.nodeId, err)
+		return
+	}
+	p.stream = stream
+}
+
+// closeStream closes the pipeline's stream, if any, and stops counting the
+// requests that were sent over it.
+func (p *pipeline) closeStream() {
+	if p.stream == nil {
+		return
+	}
+	p.inflight -= p.stream.inflight
+	p.inflightBytes -= p.stream.inflightBytes
+	p.stream.close()
+	p.stream = nil
+}
+
+// reset puts the pipeline into probing mode after NextIndex has been reset.
+func (p *pipeline) reset() {
+	p.probing = true
+	p.generation++
+}
+
+// backtrackNextIndex returns the NextIndex to try after the follower rejected
+// the entries sent at nextIndex, using the follower's conflict hints to skip
+// every entry of a conflicting term in one round trip. The node state lock
+// must be held.
+func backtrackNextIndex(nodeState *state.NodeState, nodeId string, nextIndex uint32, response *rpc.AppendEntriesResponse) uint32 {
+	var index uint32
+	if response.ConflictTerm == 0 {
+		// The follower's log ends before PrevLogIndex.
+		index = response.ConflictIndex + 1
+	} else if lastIndex, ok := nodeState.LastIndexOfTerm(response.ConflictTerm, nextIndex-1); ok {
+		// The leader has entries of the conflicting term, so the logs may match
+		// up to the last of them.
+		index = lastIndex + 1
+	} else {
+		// None of the follower's entries of the conflicting term can match.
+		index = response.ConflictIndex
+	}
+
+	// Always make progress, but never back up past entries that the follower
+	// is known to have.
+	if index >= nextIndex {
+		index = nextIndex - 1
+	}
+	if index <= nodeState.MatchIndex[nodeId] {
+		index = nodeState.MatchIndex[nodeId] + 1
+	}
+	return index
+}
+
+// sendTimeoutNow tells the target of a leadership transfer to start an
+// election right away.
+func sendTimeoutNow(nodeId string, address string, term uint32) {
+	global.Log.Infof("Transferring leadership to %s", nodeId)
+	response, err := rpc.SendTimeoutNow(address, &rpc.TimeoutNowRequest{Term: term, LeaderId: global.Config.NodeId})
+	if err != nil {
+		global.Log.Warningf("TimeoutNow to %s failed: %v", nodeId, err)
+		return
+	}
+
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	nodeState.StepDown(response.Term)
+	nodeState.Unlock()
+}
+
+// sendInstallSnapshot sends the follower the leader's latest snapshot and
+// updates NextIndex and MatchIndex based on the response.
+//
+// It returns nil if there are more entries to send to the follower right
+// away, or otherwise a channel that is closed when new entries are appended,
+// and whether the snapshot was sent.
+func sendInstallSnapshot(nodeId string, address string, term uint32) (<-chan bool, bool) {
+	nodeState := state.GetNodeState()
+
+	nodeState.Lock()
+	entriesAppended := nodeState.EntriesAppended()
+	if nodeState.Role() != state.Leader || nodeState.CurrentTerm() != term {
+		nodeState.Unlock()
+		return entriesAppended, true
+	}
+	meta, err := nodeState.LatestSnapshot()
+	nodeState.Unlock()
+	if err != nil || meta == nil {
+		global.Log.Error("Failed to retrieve snapshot for", nodeId, err)
+		return entriesAppended, false
+	}
+
+	_, reader, err := nodeState.SnapshotStore.Open(meta.Id)
+	if err != nil {
+		global.Log.Error("Failed to open snapshot:", err.Error())
+		return entriesAppended, false
+	}
+	defer reader.Close()
+
+	global.Log.Infof("Sending snapshot at index %d to %s", meta.LastIncludedIndex, nodeId)
+	response, err := rpc.SendSnapshot(address, term, global.Config.NodeId, *meta, reader)
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	if err != nil {
+		global.Log.Debugf("InstallSnapshot to %s failed: %v", nodeId, err)
+		return nodeState.EntriesAppended(), false
+	}
+
+	if nodeState.StepDown(response.Term) || nodeState.Role() != state.Leader || nodeState.CurrentTerm() != term {
+		return nodeState.EntriesAppended(), true
+	}
+	nodeState.LastContact[nodeId] = time.Now()
+	if !response.Installed {
+		return nodeState.EntriesAppended(), true
+	}
+
+	if meta.LastIncludedIndex > nodeState.MatchIndex[nodeId] {
+		nodeState.MatchIndex[nodeId] = meta.LastIncludedIndex
+	}
+	nodeState.NextIndex[nodeId] = nodeState.MatchIndex[nodeId] + 1
+	nodeState.AdvanceCommitIndex(global.Config.NodeId)
+
+	if nodeState.NextIndex[nodeId] <= nodeState.LogLength() {
+		return nil, true
+	}
+	return nodeState.EntriesAppended(), true
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/rpc"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// createLeaderState returns the state of the leader of a cluster of nodes 1, 2
+// and 3, with the given number of entries after its no-op entry.
+func createLeaderState(entries int) *state.NodeState {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+	global.Config.NodeId = "1"
+
+	nodeState := state.NewNodeState(
+		state.NewMemoryDataStore(),
+		state.NewMemoryDataStore(),
+		state.NewMemorySnapshotStore())
+	nodeState.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := nodeState.BecomeCandidate("1")
+	nodeState.BecomeLeader(term, "1", nodeState.Membership().NodeIds())
+	nodeState.AppendLeaderEntry("", "")
+	for i := 0; i < entries; i++ {
+		nodeState.AppendLeaderEntry("k", "value")
+	}
+	return nodeState
+}
+
+// The number of bytes that each entry created by createLeaderState counts
+// for in the inflight limits.
+const entrySize int = len("k") + len("value") + entryOverhead
+
+// newTestPipeline returns a pipeline to node 2 in the leader's term.
+func newTestPipeline(nodeState *state.NodeState) *pipeline {
+	return &pipeline{nodeId: "2", term: nodeState.CurrentTerm(), probing: true}
+}
+
+func Test_nextRequests(t *testing.T) {
+	defer func() {
+		global.Config.MaxInflightRequests = 0
+		global.Config.MaxInflightBytes = 0
+	}()
+
+	var tests = []struct {
+		name                string
+		entries             int
+		nextIndex           uint32
+		probing             bool
+		failed              bool
+		heartbeat           bool
+		maxInflightRequests uint32
+		maxInflightBytes    uint32
+
+		// The number of entries in each request, and NextIndex afterwards.
+		requestEntries []int
+		wantNextIndex  uint32
+	}{
+		{
+			name:           "probing sends one request without advancing NextIndex",
+			entries:        250,
+			nextIndex:      1,
+			probing:        true,
+			requestEntries: []int{100},
+			wantNextIndex:  1,
+		},
+		{
+			name:           "pipelining advances NextIndex as requests are sent",
+			entries:        250,
+			nextIndex:      1,
+			requestEntries: []int{100, 100, 51},
+			wantNextIndex:  252,
+		},
+		{
+			name:                "requests are capped by max_inflight_requests",
+			entries:             250,
+			nextIndex:           1,
+			maxInflightRequests: 2,
+			requestEntries:      []int{100, 100},
+			wantNextIndex:       201,
+		},
+		{
+			name:             "entries are capped by max_inflight_bytes",
+			entries:          250,
+			nextIndex:        2,
+			maxInflightBytes: uint32(3 * entrySize),
+			requestEntries:   []int{3},
+			wantNextIndex:    5,
+		},
+		{
+			name:             "a single entry is sent even if it exceeds max_inflight_bytes",
+			entries:          1,
+			nextIndex:        2,
+			maxInflightBytes: 1,
+			requestEntries:   []int{1},
+			wantNextIndex:    3,
+		},
+		{
+			name:          "nothing is sent once the follower has every entry",
+			entries:       3,
+			nextIndex:     5,
+			wantNextIndex: 5,
+		},
+		{
+			name:           "a heartbeat is sent once the follower has every entry",
+			entries:        3,
+			nextIndex:      5,
+			heartbeat:      true,
+			requestEntries: []int{0},
+			wantNextIndex:  5,
+		},
+		{
+			name:          "nothing is sent after a failure until the next heartbeat",
+			entries:       3,
+			nextIndex:     2,
+			failed:        true,
+			wantNextIndex: 2,
+		},
+		{
+			name:           "a heartbeat after a failure sends the entries",
+			entries:        3,
+			nextIndex:      2,
+			failed:         true,
+			heartbeat:      true,
+			requestEntries: []int{3},
+			wantNextIndex:  5,
+		},
+	}
+
+	for _, test := range tests {
+		global.Config.MaxInflightRequests = test.maxInflightRequests
+		global.Config.MaxInflightBytes = test.maxInflightBytes
+		nodeState := createLeaderState(test.entries)
+		nodeState.NextIndex["2"] = test.nextIndex
+		p := newTestPipeline(nodeState)
+		p.probing = test.probing
+		p.failed = test.failed
+
+		results, needsSnapshot := p.nextRequests(nodeState, test.heartbeat)
+		if needsSnapshot {
+			t.Errorf("%s: a snapshot was needed", test.name)
+		}
+
+		var requestEntries []int
+		size := 0
+		for _, result := range results {
+			requestEntries = append(requestEntries, len(result.request.Entries))
+			size += result.size
+		}
+		if len(requestEntries) != len(test.requestEntries) {
+			t.Errorf("%s: requests had %v entries, not %v", test.name, requestEntries, test.requestEntries)
+		} else {
+			for i := range requestEntries {
+				if requestEntries[i] != test.requestEntries[i] {
+					t.Errorf("%s: requests had %v entries, not %v", test.name, requestEntries, test.requestEntries)
+					break
+				}
+			}
+		}
+
+		if nodeState.NextIndex["2"] != test.wantNextIndex {
+			t.Errorf("%s: NextIndex was %d, not %d", test.name, nodeState.NextIndex["2"], test.wantNextIndex)
+		}
+		if p.inflight != len(results) || p.inflightBytes != size {
+			t.Errorf("%s: %d requests and %d bytes were in flight, not %d and %d",
+				test.name, p.inflight, p.inflightBytes, len(results), size)
+		}
+	}
+}
+
+func Test_nextRequests_WhenRequestsAreInFlight_SendsEntriesAfterThem(t *testing.T) {
+	nodeState := createLeaderState(150)
+	nodeState.NextIndex["2"] = 1
+	p := newTestPipeline(nodeState)
+	p.probing = false
+
+	first, _ := p.nextRequests(nodeState, false)
+	nodeState.AppendLeaderEntry("k", "value")
+	second, _ := p.nextRequests(nodeState, false)
+
+	if len(second) != 1 || second[0].request.PrevLogIndex != 151 || len(second[0].request.Entries) != 1 {
+		t.Error("Request for the new entry didn't follow the requests in flight:", len(first), len(second))
+	}
+	if p.inflight != len(first)+len(second) {
+		t.Error("Requests in flight were not counted:", p.inflight)
+	}
+}
+
+func Test_update(t *testing.T) {
+	var tests = []struct {
+		name       string
+		probing    bool
+		generation uint32
+		nextIndex  uint32
+		matchIndex uint32
+
+		// The result of a request with two entries after PrevLogIndex 5.
+		resultGeneration uint32
+		err              error
+		success          bool
+		conflictIndex    uint32
+
+		wantNextIndex  uint32
+		wantMatchIndex uint32
+		wantProbing    bool
+		wantGeneration uint32
+		wantFailed     bool
+	}{
+		{
+			name:           "a success ends probing",
+			probing:        true,
+			nextIndex:      6,
+			success:        true,
+			wantNextIndex:  8,
+			wantMatchIndex: 7,
+		},
+		{
+			name:           "a success doesn't move NextIndex back while pipelining",
+			nextIndex:      20,
+			matchIndex:     3,
+			success:        true,
+			wantNextIndex:  20,
+			wantMatchIndex: 7,
+		},
+		{
+			name:             "a success from an old generation updates MatchIndex but keeps probing",
+			probing:          true,
+			generation:       1,
+			nextIndex:        4,
+			matchIndex:       3,
+			resultGeneration: 0,
+			success:          true,
+			wantNextIndex:    8,
+			wantMatchIndex:   7,
+			wantProbing:      true,
+			wantGeneration:   1,
+		},
+		{
+			name:           "a failure resets NextIndex and starts probing",
+			nextIndex:      20,
+			matchIndex:     3,
+			err:            errors.New("unavailable"),
+			wantNextIndex:  4,
+			wantMatchIndex: 3,
+			wantProbing:    true,
+			wantGeneration: 1,
+			wantFailed:     true,
+		},
+		{
+			name:             "a failure from an old generation doesn't reset NextIndex again",
+			probing:          true,
+			generation:       1,
+			nextIndex:        6,
+			matchIndex:       3,
+			resultGeneration: 0,
+			err:              errors.New("unavailable"),
+			wantNextIndex:    6,
+			wantMatchIndex:   3,
+			wantProbing:      true,
+			wantGeneration:   1,
+			wantFailed:       true,
+		},
+		{
+			name:           "a rejection backs up NextIndex and starts probing",
+			nextIndex:      20,
+			matchIndex:     0,
+			conflictIndex:  2,
+			wantNextIndex:  3,
+			wantMatchIndex: 0,
+			wantProbing:    true,
+			wantGeneration: 1,
+		},
+		{
+			name:             "a rejection from an old generation is ignored",
+			probing:          true,
+			generation:       1,
+			nextIndex:        6,
+			resultGeneration: 0,
+			conflictIndex:    2,
+			wantNextIndex:    6,
+			wantProbing:      true,
+			wantGeneration:   1,
+		},
+	}
+
+	for _, test := range tests {
+		nodeState := createLeaderState(20)
+		nodeState.NextIndex["2"] = test.nextIndex
+		nodeState.MatchIndex["2"] = test.matchIndex
+		p := newTestPipeline(nodeState)
+		p.probing = test.probing
+		p.generation = test.generation
+		p.inflight = 1
+		p.inflightBytes = 2 * entrySize
+
+		result := appendResult{
+			request: &rpc.AppendEntriesRequest{
+				Term:         p.term,
+				PrevLogIndex: 5,
+				Entries:      []*rpc.AppendEntriesRequest_Entry{{}, {}},
+			},
+			size:       2 * entrySize,
+			generation: test.resultGeneration,
+			err:        test.err,
+		}
+		if test.err == nil {
+			result.response = &rpc.AppendEntriesResponse{
+				Term:          p.term,
+				Success:       test.success,
+				ConflictIndex: test.conflictIndex,
+			}
+		}
+
+		p.update(nodeState, result)
+
+		if nodeState.NextIndex["2"] != test.wantNextIndex || nodeState.MatchIndex["2"] != test.wantMatchIndex {
+			t.Errorf("%s: NextIndex and MatchIndex were %d and %d, not %d and %d", test.name,
+				nodeState.NextIndex["2"], nodeState.MatchIndex["2"], test.wantNextIndex, test.wantMatchIndex)
+		}
+		if p.probing != test.wantProbing || p.generation != test.wantGeneration || p.failed != test.wantFailed {
+			t.Errorf("%s: probing, generation and failed were %t, %d and %t, not %t, %d and %t", test.name,
+				p.probing, p.generation, p.failed, test.wantProbing, test.wantGeneration, test.wantFailed)
+		}
+		if p.inflight != 0 || p.inflightBytes != 0 {
+			t.Errorf("%s: %d requests and %d bytes were still in flight", test.name, p.inflight, p.inflightBytes)
+		}
+	}
+}
+
+func Test_update_WhenFollowerHasNewerTerm_StepsDown(t *testing.T) {
+	nodeState := createLeaderState(1)
+	p := newTestPipeline(nodeState)
+	p.inflight = 1
+
+	p.update(nodeState, appendResult{
+		request:  &rpc.AppendEntriesRequest{Term: p.term, PrevLogIndex: 1},
+		response: &rpc.AppendEntriesResponse{Term: p.term + 1},
+	})
+
+	if nodeState.Role() != state.Follower || nodeState.CurrentTerm() != p.term+1 {
+		t.Error("Leader did not step down:", nodeState.Role(), nodeState.CurrentTerm())
+	}
+}
+
+func Test_backtrackNextIndex(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	// The leader's log has terms 1 1 1 3 3 3 5 5 at indices 1 to 8.
+	nodeState := state.NewNodeState(
+		state.NewMemoryDataStore(),
+		state.NewMemoryDataStore(),
+		state.NewMemorySnapshotStore())
+	for i, term := range []uint32{1, 1, 1, 3, 3, 3, 5, 5} {
+		nodeState.SetLogEntry(uint32(i+1), state.LogEntry{Term: term})
+	}
+
+	var tests = []struct {
+		name          string
+		nextIndex     uint32
+		matchIndex    uint32
+		conflictTerm  uint32
+		conflictIndex uint32
+		want          uint32
+	}{
+		{"follower's log is too short", 9, 0, 0, 4, 5},
+		{"leader has the conflicting term", 9, 0, 3, 2, 7},
+		{"leader doesn't have the conflicting term", 9, 0, 4, 5, 5},
+		{"conflicting term is older than the leader's first entries", 9, 0, 2, 4, 4},
+		{"hint past the rejected entry still backs up", 5, 0, 0, 6, 4},
+		{"never backs up past MatchIndex", 9, 5, 4, 3, 6},
+	}
+
+	for _, test := range tests {
+		nodeState.MatchIndex = map[string]uint32{"2": test.matchIndex}
+		response := &rpc.AppendEntriesResponse{ConflictTerm: test.conflictTerm, ConflictIndex: test.conflictIndex}
+		got := backtrackNextIndex(nodeState, "2", test.nextIndex, response)
+		if got != test.want {
+			t.Errorf("%s: NextIndex was %d, not %d", test.name, got, test.want)
+		}
+	}
+}
+
+func Test_sendInstallSnapshot_WhenFollowerFails_ReportsSnapshotNotSent(t *testing.T) {
+	address, stop := startTestFollower(t, &testFollower{respond: succeed})
+	defer stop()
+
+	nodeState := createLeaderState(3)
+	state.Node = nodeState
+	nodeState.SetCommitIndex(4)
+	err := nodeState.ApplyCommittedEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nodeState.TakeSnapshot(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entriesAppended, sent := sendInstallSnapshot("2", address, nodeState.CurrentTerm())
+	if sent || entriesAppended == nil {
+		t.Error("Snapshot that failed was reported as sent:", sent)
+	}
+	if nodeState.NextIndex["2"] > nodeState.LogOffset() {
+		t.Error("NextIndex was advanced:", nodeState.NextIndex["2"])
+	}
+}
@@ -0,0 +1,243 @@
+package rpc
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// adminServer is used to implement the Admin gRPC server, which lets operators
+// add and remove nodes through the leader.
+type adminServer struct{}
+
+// AddNode adds the node to the cluster as a voter or a learner, returning once
+// the new membership has been committed. The node should be started with
+// join_cluster enabled.
+func (s *adminServer) AddNode(ctx context.Context, request *AddNodeRequest) (*AddNodeResponse, error) {
+	if request.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node id must not be empty")
+	} else if request.Host == nil || request.Host.Url == "" || request.Host.RpcPort == 0 {
+		return nil, status.Error(codes.InvalidArgument, "host must have a url and rpc port")
+	}
+
+	host := global.NodeHost{Url: request.Host.Url, ApiPort: request.Host.ApiPort, RpcPort: request.Host.RpcPort}
+	membership, err := changeMembership(ctx, func(membership *state.Membership) error {
+		if membership.Contains(request.NodeId) {
+			return status.Error(codes.AlreadyExists, "node is already in the cluster")
+		}
+
+		if request.Learner {
+			membership.Learners[request.NodeId] = host
+		} else {
+			membership.Nodes[request.NodeId] = host
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AddNodeResponse{Nodes: nodeHostsToProto(membership.Nodes), Learners: nodeHostsToProto(membership.Learners)}, nil
+}
+
+// RemoveNode removes the voter or learner from the cluster, returning once the
+// new membership has been committed. If the leader removes itself, it steps
+// down once the change is committed.
+func (s *adminServer) RemoveNode(ctx context.Context, request *RemoveNodeRequest) (*RemoveNodeResponse, error) {
+	if request.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node id must not be empty")
+	}
+
+	membership, err := changeMembership(ctx, func(membership *state.Membership) error {
+		if !membership.Contains(request.NodeId) {
+			return status.Error(codes.NotFound, "node is not in the cluster")
+		}
+		delete(membership.Nodes, request.NodeId)
+		delete(membership.Learners, request.NodeId)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveNodeResponse{Nodes: nodeHostsToProto(membership.Nodes), Learners: nodeHostsToProto(membership.Learners)}, nil
+}
+
+// PromoteNode makes the learner a voter, returning once the new membership has
+// been committed. Learners are only promoted once they have caught up with the
+// leader, so that they don't hold up commitment.
+func (s *adminServer) PromoteNode(ctx context.Context, request *PromoteNodeRequest) (*PromoteNodeResponse, error) {
+	if request.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node id must not be empty")
+	}
+
+	membership, err := changeMembership(ctx, func(membership *state.Membership) error {
+		host, ok := membership.Learners[request.NodeId]
+		if !ok {
+			return status.Error(codes.NotFound, "node is not a learner")
+		} else if !state.GetNodeState().CaughtUp(request.NodeId) {
+			return status.Error(codes.FailedPrecondition, "learner has not caught up with the leader")
+		}
+
+		delete(membership.Learners, request.NodeId)
+		membership.Nodes[request.NodeId] = host
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PromoteNodeResponse{Nodes: nodeHostsToProto(membership.Nodes), Learners: nodeHostsToProto(membership.Learners)}, nil
+}
+
+// TransferLeadership moves leadership to another voter, returning once the
+// leader has stepped down and heard from the target as the new leader. The
+// leader refuses proposals during the transfer, and the transfer is aborted if
+// it doesn't finish within an election timeout, or if the leader steps down
+// for another reason, such as another node becoming leader.
+func (s *adminServer) TransferLeadership(ctx context.Context, request *TransferLeadershipRequest) (*TransferLeadershipResponse, error) {
+	nodeState := state.GetNodeState()
+
+	nodeState.Lock()
+	target, err := nodeState.StartLeadershipTransfer(global.Config.NodeId, request.NodeId)
+	switch err {
+	case nil:
+	case state.ErrNotLeader:
+		defer nodeState.Unlock()
+		return nil, notLeaderError(nodeState)
+	case state.ErrTransferInProgress:
+		nodeState.Unlock()
+		return nil, status.Error(codes.Aborted, err.Error())
+	default:
+		nodeState.Unlock()
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	term := nodeState.CurrentTerm()
+	roleChanged := nodeState.RoleChanged()
+	nodeState.Unlock()
+
+	global.Log.Infof("Starting leadership transfer to %s", target)
+
+	// Once the leader steps down, the transfer has only succeeded if the next
+	// leader it hears from is the target.
+	timeout := time.After(time.Duration(global.Config.ElectionTimeout) * time.Millisecond)
+	for err == nil {
+		select {
+		case <-roleChanged:
+		case <-timeout:
+			err = status.Error(codes.Aborted, "leadership transfer timed out")
+			continue
+		case <-ctx.Done():
+			err = status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+			continue
+		}
+
+		nodeState.Lock()
+		leaderId := nodeState.LeaderId
+		if nodeState.Role() == state.Leader && nodeState.CurrentTerm() == term {
+			leaderId = ""
+		}
+		roleChanged = nodeState.RoleChanged()
+		nodeState.Unlock()
+
+		if leaderId == target {
+			return &TransferLeadershipResponse{NodeId: target}, nil
+		} else if leaderId != "" {
+			err = status.Error(codes.Aborted, "another node became leader during the transfer")
+		}
+	}
+
+	global.Log.Warningf("Aborting leadership transfer to %s", target)
+	nodeState.Lock()
+	if nodeState.CurrentTerm() == term {
+		nodeState.EndLeadershipTransfer()
+	}
+	nodeState.Unlock()
+	return nil, err
+}
+
+// changeMembership starts moving the cluster to the voters and learners made
+// by change from a copy of the current ones, and waits until the new
+// membership has been committed. Only one change can be in progress at a
+// time. The change function is called with the node state lock held.
+func changeMembership(ctx context.Context, change func(membership *state.Membership) error) (state.Membership, error) {
+	nodeState := state.GetNodeState()
+
+	nodeState.Lock()
+	if nodeState.Role() != state.Leader {
+		defer nodeState.Unlock()
+		return state.Membership{}, notLeaderError(nodeState)
+	}
+
+	current := nodeState.Membership()
+	membership := state.Membership{
+		Nodes:    make(map[string]global.NodeHost),
+		Learners: make(map[string]global.NodeHost),
+	}
+	for nodeId, host := range current.Nodes {
+		membership.Nodes[nodeId] = host
+	}
+	for nodeId, host := range current.Learners {
+		membership.Learners[nodeId] = host
+	}
+	err := change(&membership)
+	if err != nil {
+		nodeState.Unlock()
+		return state.Membership{}, err
+	}
+
+	index, term, err := nodeState.ChangeMembership(membership.Nodes, membership.Learners)
+	switch err {
+	case nil:
+	case state.ErrNotLeader:
+		defer nodeState.Unlock()
+		return state.Membership{}, notLeaderError(nodeState)
+	case state.ErrMembershipChangePending, state.ErrTransferInProgress:
+		nodeState.Unlock()
+		return state.Membership{}, status.Error(codes.Aborted, err.Error())
+	case state.ErrNoNodes:
+		nodeState.Unlock()
+		return state.Membership{}, status.Error(codes.FailedPrecondition, "the last voter can't be removed")
+	default:
+		nodeState.Unlock()
+		return state.Membership{}, status.Error(codes.Internal, err.Error())
+	}
+	// A single node cluster commits the change right away.
+	nodeState.AdvanceCommitIndex(global.Config.NodeId)
+	nodeState.Unlock()
+
+	return waitForMembership(ctx, index, term)
+}
+
+// waitForMembership waits until the leader has committed a membership that
+// isn't joint at or after the index, returning it.
+func waitForMembership(ctx context.Context, index uint32, term uint32) (state.Membership, error) {
+	nodeState := state.GetNodeState()
+	for {
+		nodeState.Lock()
+		if nodeState.CurrentTerm() != term {
+			nodeState.Unlock()
+			return state.Membership{}, status.Error(codes.Unknown, "leadership changed before the membership change was committed")
+		}
+
+		// A leader that removed itself steps down once the change is committed,
+		// so the role isn't checked.
+		membership := nodeState.Membership()
+		if !membership.IsJoint() && nodeState.MembershipIndex() >= index && nodeState.MembershipCommitted() {
+			nodeState.Unlock()
+			return membership, nil
+		}
+		commitIndexChanged := nodeState.CommitIndexChanged()
+		roleChanged := nodeState.RoleChanged()
+		nodeState.Unlock()
+
+		select {
+		case <-commitIndexChanged:
+		case <-roleChanged:
+		case <-ctx.Done():
+			return state.Membership{}, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+		}
+	}
+}
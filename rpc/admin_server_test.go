@@ -0,0 +1,340 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// newAdminClient returns an AdminClient connected to the test server, along
+// with a function that closes the connection.
+func newAdminClient(t *testing.T) (AdminClient, func()) {
+	conn, err := grpc.Dial("127.0.0.1:"+apiPort, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAdminClient(conn), func() { conn.Close() }
+}
+
+// acknowledgeEntries acts as a follower with the given id that has every entry
+// in the leader's log, until the returned function is called.
+func acknowledgeEntries(nodeId string) func() {
+	return runInBackground(func(nodeState *state.NodeState) {
+		nodeState.Lock()
+		if _, ok := nodeState.MatchIndex[nodeId]; ok {
+			nodeState.MatchIndex[nodeId] = nodeState.LogLength()
+			nodeState.AdvanceCommitIndex("1")
+		}
+		nodeState.Unlock()
+	})
+}
+
+func Test_AddNode_WhenLeader_CommitsNewMembership(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+	defer acknowledgeEntries("2")()
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response, err := client.AddNode(ctx, &AddNodeRequest{
+		NodeId: "2",
+		Host:   &NodeHost{Url: "10.0.0.2", ApiPort: 8002, RpcPort: 9002},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Nodes) != 2 || response.Nodes["2"].Url != "10.0.0.2" {
+		t.Error("Nodes did not include node 2:", response.Nodes)
+	}
+
+	state.Node.Lock()
+	defer state.Node.Unlock()
+	membership := state.Node.Membership()
+	if membership.IsJoint() || !membership.Contains("2") || !state.Node.MembershipCommitted() {
+		t.Error("Membership with node 2 was not committed:", membership)
+	}
+}
+
+func Test_AddNode_WhenChangeIsInProgress_ReturnsAborted(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	// Node 2 never acknowledges the joint membership, so the change can't be
+	// committed.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := client.AddNode(ctx, &AddNodeRequest{NodeId: "2", Host: &NodeHost{Url: "10.0.0.2", RpcPort: 9002}})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Error("Error was not DeadlineExceeded:", err)
+	}
+
+	_, err = client.AddNode(context.Background(), &AddNodeRequest{NodeId: "3", Host: &NodeHost{Url: "10.0.0.3", RpcPort: 9003}})
+	if status.Code(err) != codes.Aborted {
+		t.Error("Error was not Aborted:", err)
+	}
+}
+
+func Test_AddNode_WithExistingNode_ReturnsAlreadyExists(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	_, err := client.AddNode(context.Background(), &AddNodeRequest{NodeId: "1", Host: &NodeHost{Url: "127.0.0.1", RpcPort: 9000}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Error("Error was not AlreadyExists:", err)
+	}
+}
+
+func Test_RemoveNode_WithInvalidNodes_ReturnsErrors(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	var tests = []struct {
+		nodeId string
+		code   codes.Code
+	}{
+		{"", codes.InvalidArgument},
+		{"2", codes.NotFound},
+		// The cluster only contains the leader.
+		{"1", codes.FailedPrecondition},
+	}
+
+	for _, test := range tests {
+		_, err := client.RemoveNode(context.Background(), &RemoveNodeRequest{NodeId: test.nodeId})
+		if status.Code(err) != test.code {
+			t.Errorf("Error for node %q was not %s: %v", test.nodeId, test.code, err)
+		}
+	}
+}
+
+func Test_RemoveNode_WhenNotLeader_ReturnsNotLeader(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.NodeId = "1"
+	state.Node.Bootstrap(map[string]global.NodeHost{
+		"1": {Url: "127.0.0.1", ApiPort: 8000},
+		"2": {Url: "10.0.0.2", ApiPort: 8002},
+	})
+	state.Node.LeaderId = "2"
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	_, err := client.RemoveNode(context.Background(), &RemoveNodeRequest{NodeId: "1"})
+	notLeader, ok := LeaderHint(err)
+	if !ok {
+		t.Fatal("Error did not include a leader hint:", err)
+	}
+	if notLeader.LeaderAddress != "10.0.0.2:8002" {
+		t.Error("Leader address was not 10.0.0.2:8002:", notLeader.LeaderAddress)
+	}
+}
+
+func Test_PromoteNode_WhenLearnerHasCaughtUp_MakesLearnerVoter(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The learner doesn't count toward commitment, so adding it is committed
+	// without it acknowledging anything.
+	response, err := client.AddNode(ctx, &AddNodeRequest{
+		NodeId:  "2",
+		Host:    &NodeHost{Url: "10.0.0.2", RpcPort: 9002},
+		Learner: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Nodes) != 1 || response.Learners["2"] == nil {
+		t.Error("Node 2 was not added as a learner:", response)
+	}
+
+	state.Node.Lock()
+	for state.Node.LogLength() <= state.MaxPromotionLag {
+		state.Node.AppendLeaderEntry("a", "A")
+	}
+	state.Node.AdvanceCommitIndex("1")
+	state.Node.Unlock()
+
+	_, err = client.PromoteNode(ctx, &PromoteNodeRequest{NodeId: "2"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Error("Error for a learner that is behind was not FailedPrecondition:", err)
+	}
+
+	defer acknowledgeEntries("2")()
+	time.Sleep(50 * time.Millisecond)
+
+	promoted, err := client.PromoteNode(ctx, &PromoteNodeRequest{NodeId: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted.Nodes) != 2 || len(promoted.Learners) != 0 {
+		t.Error("Node 2 was not promoted to a voter:", promoted)
+	}
+}
+
+func Test_TransferLeadership_WhenTargetDoesNotTakeOver_TimesOutAndAcceptsProposals(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+	global.Config.ElectionTimeout = 200
+
+	client, closeClient := newAdminClient(t)
+	defer closeClient()
+
+	_, err := client.TransferLeadership(context.Background(), &TransferLeadershipRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Error("Error for a single node cluster was not FailedPrecondition:", err)
+	}
+
+	// Node 2 joins as a voter that has every entry.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer acknowledgeEntries("2")()
+	_, err = client.AddNode(ctx, &AddNodeRequest{NodeId: "2", Host: &NodeHost{Url: "10.0.0.2", RpcPort: 9002}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Node 2 never takes over, so the transfer is aborted.
+	transferred := make(chan error)
+	go func() {
+		_, err := client.TransferLeadership(context.Background(), &TransferLeadershipRequest{NodeId: "2"})
+		transferred <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	kvClient, closeKvClient := newKeyValueStoreClient(t)
+	defer closeKvClient()
+
+	_, err = kvClient.Put(ctx, &PutRequest{Key: "a", Value: "A"})
+	if status.Code(err) != codes.Unavailable {
+		t.Error("Put during the transfer was not Unavailable:", err)
+	}
+
+	err = <-transferred
+	if status.Code(err) != codes.Aborted {
+		t.Error("Error for a transfer that timed out was not Aborted:", err)
+	}
+
+	state.Node.Lock()
+	defer state.Node.Unlock()
+	if state.Node.TransferringLeadership() || state.Node.Role() != state.Leader {
+		t.Error("Leader did not end the transfer:", state.Node.Role())
+	}
+}
+
+// startTransferToNode2 makes the test node the leader of a cluster with node
+// 2, and starts transferring leadership to node 2 in the background. It
+// returns channels that receive the transfer's response and error, and a
+// function that stops the test's background work.
+func startTransferToNode2(t *testing.T) (<-chan *TransferLeadershipResponse, <-chan error, func()) {
+	stop := becomeSingleNodeLeader()
+	stopAcknowledging := acknowledgeEntries("2")
+	global.Config.ElectionTimeout = 2000
+
+	client, closeClient := newAdminClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := client.AddNode(ctx, &AddNodeRequest{NodeId: "2", Host: &NodeHost{Url: "10.0.0.2", RpcPort: 9002}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(chan *TransferLeadershipResponse, 1)
+	errs := make(chan error, 1)
+	go func() {
+		response, err := client.TransferLeadership(ctx, &TransferLeadershipRequest{NodeId: "2"})
+		responses <- response
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	return responses, errs, func() {
+		cancel()
+		closeClient()
+		stopAcknowledging()
+		stop()
+	}
+}
+
+func Test_TransferLeadership_WhenTargetBecomesLeader_ReturnsTarget(t *testing.T) {
+	resetTestEnvironment()
+
+	responses, errs, stop := startTransferToNode2(t)
+	defer stop()
+
+	// The target's election makes the leader step down before it hears from
+	// the target as the new leader.
+	state.Node.Lock()
+	state.Node.StepDown(state.Node.CurrentTerm() + 1)
+	state.Node.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	state.Node.Lock()
+	state.Node.BecomeFollower("2")
+	state.Node.Unlock()
+
+	response, err := <-responses, <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.NodeId != "2" {
+		t.Error("NodeId was not 2:", response.NodeId)
+	}
+}
+
+func Test_TransferLeadership_WhenAnotherNodeBecomesLeader_ReturnsAborted(t *testing.T) {
+	resetTestEnvironment()
+
+	_, errs, stop := startTransferToNode2(t)
+	defer stop()
+
+	state.Node.Lock()
+	state.Node.StepDown(state.Node.CurrentTerm() + 1)
+	state.Node.BecomeFollower("3")
+	state.Node.Unlock()
+
+	select {
+	case err := <-errs:
+		if status.Code(err) != codes.Aborted {
+			t.Error("Error for a transfer won by another node was not Aborted:", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Transfer did not end when another node became leader")
+	}
+}
@@ -1,42 +1,128 @@
 package rpc
 
 import (
+	"time"
+
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
+
+	"github.com/thomasylee/GoRaft/global"
 )
 
+// The deadline for requests to other nodes when rpc_timeout is not configured.
+const defaultRpcTimeout time.Duration = time.Second
+
+// rpcTimeout returns the deadline for a single request to another node.
+func rpcTimeout() time.Duration {
+	if global.Config.RpcTimeout == 0 {
+		return defaultRpcTimeout
+	}
+	return time.Duration(global.Config.RpcTimeout) * time.Millisecond
+}
+
 // SendAppendEntries sends an AppendEntries request to the specified address.
 func SendAppendEntries(address string, request *AppendEntriesRequest) (*AppendEntriesResponse, error) {
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := Pool.Get(address)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	client := NewGoRaftClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+
+	return NewGoRaftClient(conn).AppendEntries(ctx, request)
+}
+
+// AppendEntriesStream sends AppendEntries requests to a single follower over
+// a long-lived stream. Send and Recv may be called from different goroutines,
+// and responses are received in the order the requests were sent.
+type AppendEntriesStream struct {
+	stream GoRaft_AppendEntriesStreamClient
+	cancel context.CancelFunc
+}
+
+// OpenAppendEntriesStream opens an AppendEntries stream to the specified
+// address. A node that doesn't support streams fails the first Recv with
+// Unimplemented.
+func OpenAppendEntriesStream(address string) (*AppendEntriesStream, error) {
+	conn, err := Pool.Get(address)
+	if err != nil {
+		return nil, err
+	}
 
-	response, err := client.AppendEntries(context.Background(), request)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewGoRaftClient(conn).AppendEntriesStream(ctx)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	return &AppendEntriesStream{stream: stream, cancel: cancel}, nil
+}
+
+// Send sends the request down the stream.
+func (s *AppendEntriesStream) Send(request *AppendEntriesRequest) error {
+	return s.stream.Send(request)
+}
+
+// Recv returns the response to the oldest request that hasn't been answered.
+// It should only be called once a request has been sent, since the stream is
+// closed if no response arrives within the RPC timeout.
+func (s *AppendEntriesStream) Recv() (*AppendEntriesResponse, error) {
+	// The stream lives much longer than any one request, so it is only
+	// canceled if a single response takes too long.
+	watchdog := time.AfterFunc(rpcTimeout(), s.cancel)
+	defer watchdog.Stop()
 
-	return response, nil
+	return s.stream.Recv()
+}
+
+// Close closes the stream.
+func (s *AppendEntriesStream) Close() {
+	s.cancel()
 }
 
 // SendRequestVote sends a RequestVote request to the specified address.
 func SendRequestVote(address string, request *RequestVoteRequest) (*RequestVoteResponse, error) {
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := Pool.Get(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+
+	return NewGoRaftClient(conn).RequestVote(ctx, request)
+}
+
+// SendTimeoutNow sends a TimeoutNow request to the specified address.
+func SendTimeoutNow(address string, request *TimeoutNowRequest) (*TimeoutNowResponse, error) {
+	conn, err := Pool.Get(address)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	client := NewGoRaftClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout())
+	defer cancel()
+
+	return NewGoRaftClient(conn).TimeoutNow(ctx, request)
+}
+
+// SendPut sends a Put request to the client API at the specified address.
+func SendPut(ctx context.Context, address string, request *PutRequest) (*PutResponse, error) {
+	conn, err := Pool.Get(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyValueStoreClient(conn).Put(ctx, request)
+}
 
-	response, err := client.RequestVote(context.Background(), request)
+// SendDelete sends a Delete request to the client API at the specified
+// address.
+func SendDelete(ctx context.Context, address string, request *DeleteRequest) (*DeleteResponse, error) {
+	conn, err := Pool.Get(address)
 	if err != nil {
 		return nil, err
 	}
 
-	return response, nil
+	return NewKeyValueStoreClient(conn).Delete(ctx, request)
 }
@@ -13,6 +13,26 @@ It has these top-level messages:
 	AppendEntriesResponse
 	RequestVoteRequest
 	RequestVoteResponse
+	TimeoutNowRequest
+	TimeoutNowResponse
+	InstallSnapshotRequest
+	InstallSnapshotResponse
+	NodeHost
+	PutRequest
+	PutResponse
+	GetRequest
+	GetResponse
+	DeleteRequest
+	DeleteResponse
+	AddNodeRequest
+	AddNodeResponse
+	RemoveNodeRequest
+	RemoveNodeResponse
+	PromoteNodeRequest
+	PromoteNodeResponse
+	TransferLeadershipRequest
+	TransferLeadershipResponse
+	NotLeader
 */
 package rpc
 
@@ -95,6 +115,7 @@ func (m *AppendEntriesRequest) GetLeaderCommit() uint32 {
 type AppendEntriesRequest_Entry struct {
 	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
 	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	Term  uint32 `protobuf:"varint,3,opt,name=term" json:"term,omitempty"`
 }
 
 func (m *AppendEntriesRequest_Entry) Reset()                    { *m = AppendEntriesRequest_Entry{} }
@@ -116,9 +137,23 @@ func (m *AppendEntriesRequest_Entry) GetValue() string {
 	return ""
 }
 
+func (m *AppendEntriesRequest_Entry) GetTerm() uint32 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
 type AppendEntriesResponse struct {
 	Term    uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
 	Success bool   `protobuf:"varint,2,opt,name=success" json:"success,omitempty"`
+	// Hints that let the leader skip whole terms when the follower's log
+	// doesn't match at prevLogIndex. If the follower has an entry there with a
+	// different term, conflictTerm is that term and conflictIndex is the first
+	// index with that term. If the follower's log is too short, conflictTerm
+	// is 0 and conflictIndex is the follower's log length.
+	ConflictTerm  uint32 `protobuf:"varint,3,opt,name=conflictTerm" json:"conflictTerm,omitempty"`
+	ConflictIndex uint32 `protobuf:"varint,4,opt,name=conflictIndex" json:"conflictIndex,omitempty"`
 }
 
 func (m *AppendEntriesResponse) Reset()                    { *m = AppendEntriesResponse{} }
@@ -140,11 +175,32 @@ func (m *AppendEntriesResponse) GetSuccess() bool {
 	return false
 }
 
+func (m *AppendEntriesResponse) GetConflictTerm() uint32 {
+	if m != nil {
+		return m.ConflictTerm
+	}
+	return 0
+}
+
+func (m *AppendEntriesResponse) GetConflictIndex() uint32 {
+	if m != nil {
+		return m.ConflictIndex
+	}
+	return 0
+}
+
 type RequestVoteRequest struct {
 	Term         uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
 	CandidateId  string `protobuf:"bytes,2,opt,name=candidateId" json:"candidateId,omitempty"`
 	LastLogIndex uint32 `protobuf:"varint,3,opt,name=lastLogIndex" json:"lastLogIndex,omitempty"`
 	LastLogTerm  uint32 `protobuf:"varint,4,opt,name=lastLogTerm" json:"lastLogTerm,omitempty"`
+	// Whether this is a pre-vote, which asks if the vote would be granted in
+	// the given term without changing the term or vote of the node asked.
+	PreVote bool `protobuf:"varint,5,opt,name=preVote" json:"preVote,omitempty"`
+	// Whether the candidate is starting the election because the leader is
+	// transferring leadership to it, so that nodes that recently heard from
+	// the leader still vote.
+	LeadershipTransfer bool `protobuf:"varint,6,opt,name=leadershipTransfer" json:"leadershipTransfer,omitempty"`
 }
 
 func (m *RequestVoteRequest) Reset()                    { *m = RequestVoteRequest{} }
@@ -180,6 +236,20 @@ func (m *RequestVoteRequest) GetLastLogTerm() uint32 {
 	return 0
 }
 
+func (m *RequestVoteRequest) GetPreVote() bool {
+	if m != nil {
+		return m.PreVote
+	}
+	return false
+}
+
+func (m *RequestVoteRequest) GetLeadershipTransfer() bool {
+	if m != nil {
+		return m.LeadershipTransfer
+	}
+	return false
+}
+
 type RequestVoteResponse struct {
 	Term        uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
 	VoteGranted bool   `protobuf:"varint,2,opt,name=voteGranted" json:"voteGranted,omitempty"`
@@ -204,113 +274,1125 @@ func (m *RequestVoteResponse) GetVoteGranted() bool {
 	return false
 }
 
-func init() {
-	proto.RegisterType((*AppendEntriesRequest)(nil), "goraft.AppendEntriesRequest")
-	proto.RegisterType((*AppendEntriesRequest_Entry)(nil), "goraft.AppendEntriesRequest.Entry")
-	proto.RegisterType((*AppendEntriesResponse)(nil), "goraft.AppendEntriesResponse")
-	proto.RegisterType((*RequestVoteRequest)(nil), "goraft.RequestVoteRequest")
-	proto.RegisterType((*RequestVoteResponse)(nil), "goraft.RequestVoteResponse")
+// TimeoutNowRequest is sent by a leader that is transferring leadership to
+// the node, once the node's log is up to date, to make it start an election
+// right away.
+type TimeoutNowRequest struct {
+	Term     uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	LeaderId string `protobuf:"bytes,2,opt,name=leaderId" json:"leaderId,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *TimeoutNowRequest) Reset()                    { *m = TimeoutNowRequest{} }
+func (m *TimeoutNowRequest) String() string            { return proto.CompactTextString(m) }
+func (*TimeoutNowRequest) ProtoMessage()               {}
+func (*TimeoutNowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *TimeoutNowRequest) GetTerm() uint32 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
 
-// Client API for GoRaft service
+func (m *TimeoutNowRequest) GetLeaderId() string {
+	if m != nil {
+		return m.LeaderId
+	}
+	return ""
+}
 
-type GoRaftClient interface {
-	AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesResponse, error)
-	RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteResponse, error)
+type TimeoutNowResponse struct {
+	Term uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
 }
 
-type goRaftClient struct {
-	cc *grpc.ClientConn
+func (m *TimeoutNowResponse) Reset()                    { *m = TimeoutNowResponse{} }
+func (m *TimeoutNowResponse) String() string            { return proto.CompactTextString(m) }
+func (*TimeoutNowResponse) ProtoMessage()               {}
+func (*TimeoutNowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *TimeoutNowResponse) GetTerm() uint32 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
 }
 
-func NewGoRaftClient(cc *grpc.ClientConn) GoRaftClient {
-	return &goRaftClient{cc}
+// InstallSnapshotRequest is a chunk of a snapshot streamed by the leader to a
+// follower that needs log entries that the leader has already replaced with
+// the snapshot. The snapshot data is the key-value pairs of the storage state
+// machine, each as the length of the key, the key, the length of the value,
+// and the value, with the lengths as big-endian uint32s.
+type InstallSnapshotRequest struct {
+	Term              uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	LeaderId          string `protobuf:"bytes,2,opt,name=leaderId" json:"leaderId,omitempty"`
+	LastIncludedIndex uint32 `protobuf:"varint,3,opt,name=lastIncludedIndex" json:"lastIncludedIndex,omitempty"`
+	LastIncludedTerm  uint32 `protobuf:"varint,4,opt,name=lastIncludedTerm" json:"lastIncludedTerm,omitempty"`
+	// The nodes in the cluster as of the last included entry.
+	Nodes map[string]*NodeHost `protobuf:"bytes,5,rep,name=nodes" json:"nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The size and CRC-32C checksum of the whole snapshot.
+	Size     uint64 `protobuf:"varint,6,opt,name=size" json:"size,omitempty"`
+	Checksum uint32 `protobuf:"varint,7,opt,name=checksum" json:"checksum,omitempty"`
+	// The byte offset of the chunk in the snapshot, the chunk's data, and its
+	// CRC-32C checksum.
+	Offset        uint64 `protobuf:"varint,8,opt,name=offset" json:"offset,omitempty"`
+	Data          []byte `protobuf:"bytes,9,opt,name=data,proto3" json:"data,omitempty"`
+	ChunkChecksum uint32 `protobuf:"varint,10,opt,name=chunkChecksum" json:"chunkChecksum,omitempty"`
+	// Whether this is the last chunk of the snapshot.
+	Done bool `protobuf:"varint,11,opt,name=done" json:"done,omitempty"`
+	// The new nodes, if the cluster was moving to a new set of nodes as of the
+	// last included entry.
+	NewNodes map[string]*NodeHost `protobuf:"bytes,12,rep,name=newNodes" json:"newNodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The learners as of the last included entry.
+	Learners map[string]*NodeHost `protobuf:"bytes,13,rep,name=learners" json:"learners,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
-func (c *goRaftClient) AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesResponse, error) {
-	out := new(AppendEntriesResponse)
-	err := grpc.Invoke(ctx, "/goraft.GoRaft/AppendEntries", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *InstallSnapshotRequest) Reset()                    { *m = InstallSnapshotRequest{} }
+func (m *InstallSnapshotRequest) String() string            { return proto.CompactTextString(m) }
+func (*InstallSnapshotRequest) ProtoMessage()               {}
+func (*InstallSnapshotRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *InstallSnapshotRequest) GetTerm() uint32 {
+	if m != nil {
+		return m.Term
 	}
-	return out, nil
+	return 0
 }
 
-func (c *goRaftClient) RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteResponse, error) {
-	out := new(RequestVoteResponse)
-	err := grpc.Invoke(ctx, "/goraft.GoRaft/RequestVote", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *InstallSnapshotRequest) GetLeaderId() string {
+	if m != nil {
+		return m.LeaderId
 	}
-	return out, nil
+	return ""
 }
 
-// Server API for GoRaft service
+func (m *InstallSnapshotRequest) GetLastIncludedIndex() uint32 {
+	if m != nil {
+		return m.LastIncludedIndex
+	}
+	return 0
+}
 
-type GoRaftServer interface {
-	AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesResponse, error)
-	RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteResponse, error)
+func (m *InstallSnapshotRequest) GetLastIncludedTerm() uint32 {
+	if m != nil {
+		return m.LastIncludedTerm
+	}
+	return 0
 }
 
-func RegisterGoRaftServer(s *grpc.Server, srv GoRaftServer) {
-	s.RegisterService(&_GoRaft_serviceDesc, srv)
+func (m *InstallSnapshotRequest) GetNodes() map[string]*NodeHost {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
 }
 
-func _GoRaft_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(AppendEntriesRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *InstallSnapshotRequest) GetSize() uint64 {
+	if m != nil {
+		return m.Size
 	}
-	if interceptor == nil {
-		return srv.(GoRaftServer).AppendEntries(ctx, in)
+	return 0
+}
+
+func (m *InstallSnapshotRequest) GetChecksum() uint32 {
+	if m != nil {
+		return m.Checksum
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/goraft.GoRaft/AppendEntries",
+	return 0
+}
+
+func (m *InstallSnapshotRequest) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(GoRaftServer).AppendEntries(ctx, req.(*AppendEntriesRequest))
+	return 0
+}
+
+func (m *InstallSnapshotRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-func _GoRaft_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestVoteRequest)
-	if err := dec(in); err != nil {
-		return nil, err
+func (m *InstallSnapshotRequest) GetChunkChecksum() uint32 {
+	if m != nil {
+		return m.ChunkChecksum
 	}
-	if interceptor == nil {
-		return srv.(GoRaftServer).RequestVote(ctx, in)
+	return 0
+}
+
+func (m *InstallSnapshotRequest) GetDone() bool {
+	if m != nil {
+		return m.Done
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/goraft.GoRaft/RequestVote",
+	return false
+}
+
+func (m *InstallSnapshotRequest) GetNewNodes() map[string]*NodeHost {
+	if m != nil {
+		return m.NewNodes
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(GoRaftServer).RequestVote(ctx, req.(*RequestVoteRequest))
+	return nil
+}
+
+func (m *InstallSnapshotRequest) GetLearners() map[string]*NodeHost {
+	if m != nil {
+		return m.Learners
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-var _GoRaft_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "goraft.GoRaft",
-	HandlerType: (*GoRaftServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "AppendEntries",
-			Handler:    _GoRaft_AppendEntries_Handler,
-		},
-		{
-			MethodName: "RequestVote",
-			Handler:    _GoRaft_RequestVote_Handler,
+type InstallSnapshotResponse struct {
+	Term uint32 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	// The offset that the follower expects the next chunk to start at, which
+	// lets the leader resume a transfer that was interrupted.
+	NextOffset uint64 `protobuf:"varint,2,opt,name=nextOffset" json:"nextOffset,omitempty"`
+	// Whether the whole snapshot was received, verified, and installed.
+	Installed bool `protobuf:"varint,3,opt,name=installed" json:"installed,omitempty"`
+}
+
+func (m *InstallSnapshotResponse) Reset()                    { *m = InstallSnapshotResponse{} }
+func (m *InstallSnapshotResponse) String() string            { return proto.CompactTextString(m) }
+func (*InstallSnapshotResponse) ProtoMessage()               {}
+func (*InstallSnapshotResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *InstallSnapshotResponse) GetTerm() uint32 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+func (m *InstallSnapshotResponse) GetNextOffset() uint64 {
+	if m != nil {
+		return m.NextOffset
+	}
+	return 0
+}
+
+func (m *InstallSnapshotResponse) GetInstalled() bool {
+	if m != nil {
+		return m.Installed
+	}
+	return false
+}
+
+type NodeHost struct {
+	Url     string `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+	ApiPort uint32 `protobuf:"varint,2,opt,name=apiPort" json:"apiPort,omitempty"`
+	RpcPort uint32 `protobuf:"varint,3,opt,name=rpcPort" json:"rpcPort,omitempty"`
+}
+
+func (m *NodeHost) Reset()                    { *m = NodeHost{} }
+func (m *NodeHost) String() string            { return proto.CompactTextString(m) }
+func (*NodeHost) ProtoMessage()               {}
+func (*NodeHost) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *NodeHost) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *NodeHost) GetApiPort() uint32 {
+	if m != nil {
+		return m.ApiPort
+	}
+	return 0
+}
+
+func (m *NodeHost) GetRpcPort() uint32 {
+	if m != nil {
+		return m.RpcPort
+	}
+	return 0
+}
+
+type PutRequest struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *PutRequest) Reset()                    { *m = PutRequest{} }
+func (m *PutRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutRequest) ProtoMessage()               {}
+func (*PutRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *PutRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *PutRequest) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type PutResponse struct {
+}
+
+func (m *PutResponse) Reset()                    { *m = PutResponse{} }
+func (m *PutResponse) String() string            { return proto.CompactTextString(m) }
+func (*PutResponse) ProtoMessage()               {}
+func (*PutResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type GetRequest struct {
+	Key string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *GetRequest) Reset()                    { *m = GetRequest{} }
+func (m *GetRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetRequest) ProtoMessage()               {}
+func (*GetRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *GetRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type GetResponse struct {
+	Value string `protobuf:"bytes,1,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *GetResponse) Reset()                    { *m = GetResponse{} }
+func (m *GetResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetResponse) ProtoMessage()               {}
+func (*GetResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GetResponse) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type DeleteRequest struct {
+	Key string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *DeleteRequest) Reset()                    { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()               {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *DeleteRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+}
+
+func (m *DeleteResponse) Reset()                    { *m = DeleteResponse{} }
+func (m *DeleteResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteResponse) ProtoMessage()               {}
+func (*DeleteResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+type AddNodeRequest struct {
+	NodeId string    `protobuf:"bytes,1,opt,name=nodeId" json:"nodeId,omitempty"`
+	Host   *NodeHost `protobuf:"bytes,2,opt,name=host" json:"host,omitempty"`
+	// Whether the node is added as a learner, which receives the log but
+	// doesn't vote until it is promoted.
+	Learner bool `protobuf:"varint,3,opt,name=learner" json:"learner,omitempty"`
+}
+
+func (m *AddNodeRequest) Reset()                    { *m = AddNodeRequest{} }
+func (m *AddNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*AddNodeRequest) ProtoMessage()               {}
+func (*AddNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *AddNodeRequest) GetNodeId() string {
+	if m != nil {
+		return m.NodeId
+	}
+	return ""
+}
+
+func (m *AddNodeRequest) GetHost() *NodeHost {
+	if m != nil {
+		return m.Host
+	}
+	return nil
+}
+
+func (m *AddNodeRequest) GetLearner() bool {
+	if m != nil {
+		return m.Learner
+	}
+	return false
+}
+
+type AddNodeResponse struct {
+	// The voters and learners in the cluster after the change.
+	Nodes    map[string]*NodeHost `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Learners map[string]*NodeHost `protobuf:"bytes,2,rep,name=learners" json:"learners,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *AddNodeResponse) Reset()                    { *m = AddNodeResponse{} }
+func (m *AddNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*AddNodeResponse) ProtoMessage()               {}
+func (*AddNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *AddNodeResponse) GetNodes() map[string]*NodeHost {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+func (m *AddNodeResponse) GetLearners() map[string]*NodeHost {
+	if m != nil {
+		return m.Learners
+	}
+	return nil
+}
+
+// RemoveNodeRequest removes a voter or a learner.
+type RemoveNodeRequest struct {
+	NodeId string `protobuf:"bytes,1,opt,name=nodeId" json:"nodeId,omitempty"`
+}
+
+func (m *RemoveNodeRequest) Reset()                    { *m = RemoveNodeRequest{} }
+func (m *RemoveNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*RemoveNodeRequest) ProtoMessage()               {}
+func (*RemoveNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *RemoveNodeRequest) GetNodeId() string {
+	if m != nil {
+		return m.NodeId
+	}
+	return ""
+}
+
+type RemoveNodeResponse struct {
+	// The voters and learners in the cluster after the change.
+	Nodes    map[string]*NodeHost `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Learners map[string]*NodeHost `protobuf:"bytes,2,rep,name=learners" json:"learners,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *RemoveNodeResponse) Reset()                    { *m = RemoveNodeResponse{} }
+func (m *RemoveNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*RemoveNodeResponse) ProtoMessage()               {}
+func (*RemoveNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *RemoveNodeResponse) GetNodes() map[string]*NodeHost {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+func (m *RemoveNodeResponse) GetLearners() map[string]*NodeHost {
+	if m != nil {
+		return m.Learners
+	}
+	return nil
+}
+
+// PromoteNodeRequest makes a learner a voter, which is only allowed once the
+// learner has caught up with the leader's log.
+type PromoteNodeRequest struct {
+	NodeId string `protobuf:"bytes,1,opt,name=nodeId" json:"nodeId,omitempty"`
+}
+
+func (m *PromoteNodeRequest) Reset()                    { *m = PromoteNodeRequest{} }
+func (m *PromoteNodeRequest) String() string            { return proto.CompactTextString(m) }
+func (*PromoteNodeRequest) ProtoMessage()               {}
+func (*PromoteNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *PromoteNodeRequest) GetNodeId() string {
+	if m != nil {
+		return m.NodeId
+	}
+	return ""
+}
+
+type PromoteNodeResponse struct {
+	// The voters and learners in the cluster after the change.
+	Nodes    map[string]*NodeHost `protobuf:"bytes,1,rep,name=nodes" json:"nodes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Learners map[string]*NodeHost `protobuf:"bytes,2,rep,name=learners" json:"learners,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *PromoteNodeResponse) Reset()                    { *m = PromoteNodeResponse{} }
+func (m *PromoteNodeResponse) String() string            { return proto.CompactTextString(m) }
+func (*PromoteNodeResponse) ProtoMessage()               {}
+func (*PromoteNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *PromoteNodeResponse) GetNodes() map[string]*NodeHost {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+func (m *PromoteNodeResponse) GetLearners() map[string]*NodeHost {
+	if m != nil {
+		return m.Learners
+	}
+	return nil
+}
+
+// TransferLeadershipRequest moves leadership to another voter, such as before
+// restarting the leader. If nodeId is empty, the voter with the most entries
+// is chosen.
+type TransferLeadershipRequest struct {
+	NodeId string `protobuf:"bytes,1,opt,name=nodeId" json:"nodeId,omitempty"`
+}
+
+func (m *TransferLeadershipRequest) Reset()                    { *m = TransferLeadershipRequest{} }
+func (m *TransferLeadershipRequest) String() string            { return proto.CompactTextString(m) }
+func (*TransferLeadershipRequest) ProtoMessage()               {}
+func (*TransferLeadershipRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *TransferLeadershipRequest) GetNodeId() string {
+	if m != nil {
+		return m.NodeId
+	}
+	return ""
+}
+
+type TransferLeadershipResponse struct {
+	// The node that leadership was transferred to.
+	NodeId string `protobuf:"bytes,1,opt,name=nodeId" json:"nodeId,omitempty"`
+}
+
+func (m *TransferLeadershipResponse) Reset()                    { *m = TransferLeadershipResponse{} }
+func (m *TransferLeadershipResponse) String() string            { return proto.CompactTextString(m) }
+func (*TransferLeadershipResponse) ProtoMessage()               {}
+func (*TransferLeadershipResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *TransferLeadershipResponse) GetNodeId() string {
+	if m != nil {
+		return m.NodeId
+	}
+	return ""
+}
+
+// NotLeader is included in the details of the FailedPrecondition status
+// returned when a client request is sent to a node that is not the leader.
+type NotLeader struct {
+	LeaderId      string `protobuf:"bytes,1,opt,name=leaderId" json:"leaderId,omitempty"`
+	LeaderAddress string `protobuf:"bytes,2,opt,name=leaderAddress" json:"leaderAddress,omitempty"`
+}
+
+func (m *NotLeader) Reset()                    { *m = NotLeader{} }
+func (m *NotLeader) String() string            { return proto.CompactTextString(m) }
+func (*NotLeader) ProtoMessage()               {}
+func (*NotLeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *NotLeader) GetLeaderId() string {
+	if m != nil {
+		return m.LeaderId
+	}
+	return ""
+}
+
+func (m *NotLeader) GetLeaderAddress() string {
+	if m != nil {
+		return m.LeaderAddress
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*AppendEntriesRequest)(nil), "goraft.AppendEntriesRequest")
+	proto.RegisterType((*AppendEntriesRequest_Entry)(nil), "goraft.AppendEntriesRequest.Entry")
+	proto.RegisterType((*AppendEntriesResponse)(nil), "goraft.AppendEntriesResponse")
+	proto.RegisterType((*RequestVoteRequest)(nil), "goraft.RequestVoteRequest")
+	proto.RegisterType((*RequestVoteResponse)(nil), "goraft.RequestVoteResponse")
+	proto.RegisterType((*TimeoutNowRequest)(nil), "goraft.TimeoutNowRequest")
+	proto.RegisterType((*TimeoutNowResponse)(nil), "goraft.TimeoutNowResponse")
+	proto.RegisterType((*InstallSnapshotRequest)(nil), "goraft.InstallSnapshotRequest")
+	proto.RegisterType((*InstallSnapshotResponse)(nil), "goraft.InstallSnapshotResponse")
+	proto.RegisterType((*NodeHost)(nil), "goraft.NodeHost")
+	proto.RegisterType((*PutRequest)(nil), "goraft.PutRequest")
+	proto.RegisterType((*PutResponse)(nil), "goraft.PutResponse")
+	proto.RegisterType((*GetRequest)(nil), "goraft.GetRequest")
+	proto.RegisterType((*GetResponse)(nil), "goraft.GetResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "goraft.DeleteRequest")
+	proto.RegisterType((*DeleteResponse)(nil), "goraft.DeleteResponse")
+	proto.RegisterType((*AddNodeRequest)(nil), "goraft.AddNodeRequest")
+	proto.RegisterType((*AddNodeResponse)(nil), "goraft.AddNodeResponse")
+	proto.RegisterType((*RemoveNodeRequest)(nil), "goraft.RemoveNodeRequest")
+	proto.RegisterType((*RemoveNodeResponse)(nil), "goraft.RemoveNodeResponse")
+	proto.RegisterType((*PromoteNodeRequest)(nil), "goraft.PromoteNodeRequest")
+	proto.RegisterType((*PromoteNodeResponse)(nil), "goraft.PromoteNodeResponse")
+	proto.RegisterType((*TransferLeadershipRequest)(nil), "goraft.TransferLeadershipRequest")
+	proto.RegisterType((*TransferLeadershipResponse)(nil), "goraft.TransferLeadershipResponse")
+	proto.RegisterType((*NotLeader)(nil), "goraft.NotLeader")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for GoRaft service
+
+type GoRaftClient interface {
+	AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesResponse, error)
+	// AppendEntriesStream carries AppendEntries requests and their responses
+	// over a long-lived stream, answering requests in the order they are sent.
+	AppendEntriesStream(ctx context.Context, opts ...grpc.CallOption) (GoRaft_AppendEntriesStreamClient, error)
+	RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteResponse, error)
+	InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (GoRaft_InstallSnapshotClient, error)
+	TimeoutNow(ctx context.Context, in *TimeoutNowRequest, opts ...grpc.CallOption) (*TimeoutNowResponse, error)
+}
+
+type goRaftClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewGoRaftClient(cc *grpc.ClientConn) GoRaftClient {
+	return &goRaftClient{cc}
+}
+
+func (c *goRaftClient) AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesResponse, error) {
+	out := new(AppendEntriesResponse)
+	err := grpc.Invoke(ctx, "/goraft.GoRaft/AppendEntries", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goRaftClient) AppendEntriesStream(ctx context.Context, opts ...grpc.CallOption) (GoRaft_AppendEntriesStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_GoRaft_serviceDesc.Streams[0], c.cc, "/goraft.GoRaft/AppendEntriesStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &goRaftAppendEntriesStreamClient{stream}
+	return x, nil
+}
+
+type GoRaft_AppendEntriesStreamClient interface {
+	Send(*AppendEntriesRequest) error
+	Recv() (*AppendEntriesResponse, error)
+	grpc.ClientStream
+}
+
+type goRaftAppendEntriesStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *goRaftAppendEntriesStreamClient) Send(m *AppendEntriesRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *goRaftAppendEntriesStreamClient) Recv() (*AppendEntriesResponse, error) {
+	m := new(AppendEntriesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *goRaftClient) RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteResponse, error) {
+	out := new(RequestVoteResponse)
+	err := grpc.Invoke(ctx, "/goraft.GoRaft/RequestVote", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *goRaftClient) InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (GoRaft_InstallSnapshotClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_GoRaft_serviceDesc.Streams[1], c.cc, "/goraft.GoRaft/InstallSnapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &goRaftInstallSnapshotClient{stream}
+	return x, nil
+}
+
+type GoRaft_InstallSnapshotClient interface {
+	Send(*InstallSnapshotRequest) error
+	CloseAndRecv() (*InstallSnapshotResponse, error)
+	grpc.ClientStream
+}
+
+type goRaftInstallSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *goRaftInstallSnapshotClient) Send(m *InstallSnapshotRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *goRaftInstallSnapshotClient) CloseAndRecv() (*InstallSnapshotResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(InstallSnapshotResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *goRaftClient) TimeoutNow(ctx context.Context, in *TimeoutNowRequest, opts ...grpc.CallOption) (*TimeoutNowResponse, error) {
+	out := new(TimeoutNowResponse)
+	err := grpc.Invoke(ctx, "/goraft.GoRaft/TimeoutNow", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for GoRaft service
+
+type GoRaftServer interface {
+	AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesResponse, error)
+	// AppendEntriesStream carries AppendEntries requests and their responses
+	// over a long-lived stream, answering requests in the order they are sent.
+	AppendEntriesStream(GoRaft_AppendEntriesStreamServer) error
+	RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteResponse, error)
+	InstallSnapshot(GoRaft_InstallSnapshotServer) error
+	TimeoutNow(context.Context, *TimeoutNowRequest) (*TimeoutNowResponse, error)
+}
+
+func RegisterGoRaftServer(s *grpc.Server, srv GoRaftServer) {
+	s.RegisterService(&_GoRaft_serviceDesc, srv)
+}
+
+func _GoRaft_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoRaftServer).AppendEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.GoRaft/AppendEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoRaftServer).AppendEntries(ctx, req.(*AppendEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoRaft_AppendEntriesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GoRaftServer).AppendEntriesStream(&goRaftAppendEntriesStreamServer{stream})
+}
+
+type GoRaft_AppendEntriesStreamServer interface {
+	Send(*AppendEntriesResponse) error
+	Recv() (*AppendEntriesRequest, error)
+	grpc.ServerStream
+}
+
+type goRaftAppendEntriesStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *goRaftAppendEntriesStreamServer) Send(m *AppendEntriesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *goRaftAppendEntriesStreamServer) Recv() (*AppendEntriesRequest, error) {
+	m := new(AppendEntriesRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _GoRaft_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoRaftServer).RequestVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.GoRaft/RequestVote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoRaftServer).RequestVote(ctx, req.(*RequestVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GoRaft_InstallSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GoRaftServer).InstallSnapshot(&goRaftInstallSnapshotServer{stream})
+}
+
+type GoRaft_InstallSnapshotServer interface {
+	SendAndClose(*InstallSnapshotResponse) error
+	Recv() (*InstallSnapshotRequest, error)
+	grpc.ServerStream
+}
+
+type goRaftInstallSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *goRaftInstallSnapshotServer) SendAndClose(m *InstallSnapshotResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *goRaftInstallSnapshotServer) Recv() (*InstallSnapshotRequest, error) {
+	m := new(InstallSnapshotRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _GoRaft_TimeoutNow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TimeoutNowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GoRaftServer).TimeoutNow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.GoRaft/TimeoutNow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GoRaftServer).TimeoutNow(ctx, req.(*TimeoutNowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _GoRaft_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "goraft.GoRaft",
+	HandlerType: (*GoRaftServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AppendEntries",
+			Handler:    _GoRaft_AppendEntries_Handler,
+		},
+		{
+			MethodName: "RequestVote",
+			Handler:    _GoRaft_RequestVote_Handler,
+		},
+		{
+			MethodName: "TimeoutNow",
+			Handler:    _GoRaft_TimeoutNow_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AppendEntriesStream",
+			Handler:       _GoRaft_AppendEntriesStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "InstallSnapshot",
+			Handler:       _GoRaft_InstallSnapshot_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "goraft.proto",
+}
+
+// Client API for KeyValueStore service
+
+type KeyValueStoreClient interface {
+	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+}
+
+type keyValueStoreClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewKeyValueStoreClient(cc *grpc.ClientConn) KeyValueStoreClient {
+	return &keyValueStoreClient{cc}
+}
+
+func (c *keyValueStoreClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error) {
+	out := new(PutResponse)
+	err := grpc.Invoke(ctx, "/goraft.KeyValueStore/Put", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := grpc.Invoke(ctx, "/goraft.KeyValueStore/Get", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueStoreClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := grpc.Invoke(ctx, "/goraft.KeyValueStore/Delete", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for KeyValueStore service
+
+type KeyValueStoreServer interface {
+	Put(context.Context, *PutRequest) (*PutResponse, error)
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+}
+
+func RegisterKeyValueStoreServer(s *grpc.Server, srv KeyValueStoreServer) {
+	s.RegisterService(&_KeyValueStore_serviceDesc, srv)
+}
+
+func _KeyValueStore_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.KeyValueStore/Put",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.KeyValueStore/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValueStore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueStoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.KeyValueStore/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueStoreServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _KeyValueStore_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "goraft.KeyValueStore",
+	HandlerType: (*KeyValueStoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Put",
+			Handler:    _KeyValueStore_Put_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _KeyValueStore_Get_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _KeyValueStore_Delete_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "goraft.proto",
+}
+
+// Client API for Admin service
+
+type AdminClient interface {
+	AddNode(ctx context.Context, in *AddNodeRequest, opts ...grpc.CallOption) (*AddNodeResponse, error)
+	RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error)
+	PromoteNode(ctx context.Context, in *PromoteNodeRequest, opts ...grpc.CallOption) (*PromoteNodeResponse, error)
+	TransferLeadership(ctx context.Context, in *TransferLeadershipRequest, opts ...grpc.CallOption) (*TransferLeadershipResponse, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) AddNode(ctx context.Context, in *AddNodeRequest, opts ...grpc.CallOption) (*AddNodeResponse, error) {
+	out := new(AddNodeResponse)
+	err := grpc.Invoke(ctx, "/goraft.Admin/AddNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error) {
+	out := new(RemoveNodeResponse)
+	err := grpc.Invoke(ctx, "/goraft.Admin/RemoveNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) PromoteNode(ctx context.Context, in *PromoteNodeRequest, opts ...grpc.CallOption) (*PromoteNodeResponse, error) {
+	out := new(PromoteNodeResponse)
+	err := grpc.Invoke(ctx, "/goraft.Admin/PromoteNode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) TransferLeadership(ctx context.Context, in *TransferLeadershipRequest, opts ...grpc.CallOption) (*TransferLeadershipResponse, error) {
+	out := new(TransferLeadershipResponse)
+	err := grpc.Invoke(ctx, "/goraft.Admin/TransferLeadership", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Admin service
+
+type AdminServer interface {
+	AddNode(context.Context, *AddNodeRequest) (*AddNodeResponse, error)
+	RemoveNode(context.Context, *RemoveNodeRequest) (*RemoveNodeResponse, error)
+	PromoteNode(context.Context, *PromoteNodeRequest) (*PromoteNodeResponse, error)
+	TransferLeadership(context.Context, *TransferLeadershipRequest) (*TransferLeadershipResponse, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_AddNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AddNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.Admin/AddNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AddNode(ctx, req.(*AddNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RemoveNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RemoveNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.Admin/RemoveNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RemoveNode(ctx, req.(*RemoveNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_PromoteNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).PromoteNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.Admin/PromoteNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).PromoteNode(ctx, req.(*PromoteNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_TransferLeadership_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferLeadershipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).TransferLeadership(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/goraft.Admin/TransferLeadership",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).TransferLeadership(ctx, req.(*TransferLeadershipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "goraft.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddNode",
+			Handler:    _Admin_AddNode_Handler,
+		},
+		{
+			MethodName: "RemoveNode",
+			Handler:    _Admin_RemoveNode_Handler,
+		},
+		{
+			MethodName: "PromoteNode",
+			Handler:    _Admin_PromoteNode_Handler,
+		},
+		{
+			MethodName: "TransferLeadership",
+			Handler:    _Admin_TransferLeadership_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -320,28 +1402,79 @@ var _GoRaft_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("goraft.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 366 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x7c, 0x93, 0x41, 0x4f, 0xea, 0x40,
-	0x10, 0xc7, 0x5f, 0x29, 0x2d, 0x30, 0x40, 0xf2, 0x32, 0x8f, 0x97, 0x34, 0x7d, 0xcf, 0x84, 0xf4,
-	0xc4, 0x09, 0x13, 0xbc, 0x7a, 0x51, 0x43, 0x90, 0x68, 0x3c, 0x34, 0xc6, 0x83, 0xb7, 0xda, 0x1d,
-	0x09, 0x11, 0xba, 0x75, 0x77, 0x21, 0xf2, 0x2d, 0xfc, 0x06, 0x26, 0x7e, 0x52, 0xdb, 0x6d, 0x4b,
-	0x16, 0x44, 0x6e, 0xfb, 0xff, 0xef, 0xcc, 0xf4, 0x37, 0xff, 0x4d, 0xa1, 0x33, 0xe3, 0x22, 0x7a,
-	0x56, 0xc3, 0x54, 0x70, 0xc5, 0xd1, 0x2d, 0x54, 0xf0, 0x51, 0x83, 0xde, 0x45, 0x9a, 0x52, 0xc2,
-	0xc6, 0x89, 0x12, 0x73, 0x92, 0x21, 0xbd, 0xae, 0x48, 0x2a, 0x44, 0xa8, 0x2b, 0x12, 0x4b, 0xcf,
-	0xea, 0x5b, 0x83, 0x6e, 0xa8, 0xcf, 0xe8, 0x43, 0x73, 0x41, 0x11, 0x23, 0x31, 0x65, 0x5e, 0x2d,
-	0xf3, 0x5b, 0xe1, 0x56, 0x63, 0x00, 0x9d, 0x54, 0xd0, 0xfa, 0x96, 0xcf, 0xa6, 0x09, 0xa3, 0x37,
-	0xcf, 0xd6, 0x7d, 0x3b, 0x1e, 0xf6, 0xa1, 0x5d, 0xea, 0xfb, 0x7c, 0x74, 0x5d, 0x97, 0x98, 0x16,
-	0x9e, 0x43, 0x83, 0x0a, 0x0e, 0xcf, 0xe9, 0xdb, 0x83, 0xf6, 0x28, 0x18, 0x96, 0xd8, 0x87, 0x20,
-	0x87, 0xb9, 0xdc, 0x84, 0x55, 0x4b, 0xce, 0x50, 0xf0, 0x5c, 0xf1, 0xe5, 0x72, 0xae, 0x3c, 0xb7,
-	0x60, 0x30, 0x3d, 0xff, 0x14, 0x1c, 0xdd, 0x85, 0xbf, 0xc1, 0x7e, 0xa1, 0x8d, 0xde, 0xaf, 0x15,
-	0xe6, 0x47, 0xec, 0x81, 0xb3, 0x8e, 0x16, 0x2b, 0x2a, 0x77, 0x2b, 0x44, 0x30, 0x86, 0xbf, 0x7b,
-	0xdf, 0x96, 0x29, 0x4f, 0x24, 0x1d, 0x4c, 0xc8, 0x83, 0x86, 0x5c, 0xc5, 0x31, 0x49, 0xa9, 0x87,
-	0x34, 0xc3, 0x4a, 0x06, 0xef, 0x16, 0x60, 0x89, 0xfd, 0xc0, 0x15, 0x1d, 0x8b, 0x39, 0x8b, 0x29,
-	0x8e, 0x12, 0x36, 0x67, 0x91, 0xa2, 0x6d, 0xd2, 0xa6, 0xa5, 0x17, 0x8d, 0xa4, 0xda, 0x0f, 0xdb,
-	0xf4, 0xf2, 0x29, 0xa5, 0x36, 0xc3, 0x36, 0xac, 0xe0, 0x06, 0xfe, 0xec, 0x10, 0x1d, 0xd9, 0x2b,
-	0x1b, 0xb6, 0xce, 0x6a, 0x26, 0x22, 0x4a, 0x14, 0xb1, 0x72, 0x37, 0xd3, 0x1a, 0x7d, 0x5a, 0xe0,
-	0x4e, 0x78, 0x98, 0x3d, 0x15, 0xde, 0x41, 0x77, 0x27, 0x31, 0xfc, 0x7f, 0xec, 0x11, 0xfd, 0x93,
-	0x1f, 0x6e, 0x0b, 0x9c, 0xe0, 0x17, 0x5e, 0x43, 0xdb, 0xe0, 0x44, 0xbf, 0xaa, 0xff, 0x1e, 0xa7,
-	0xff, 0xef, 0xe0, 0x5d, 0x35, 0xe9, 0xd2, 0x79, 0xb4, 0x45, 0x1a, 0x3f, 0xb9, 0xfa, 0x1f, 0x38,
-	0xfb, 0x0a, 0x00, 0x00, 0xff, 0xff, 0xa9, 0xe4, 0xf6, 0xcf, 0x13, 0x03, 0x00, 0x00,
+	// 1182 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x58, 0x51, 0x6f, 0xdb, 0x36,
+	0x10, 0x8e, 0xec, 0xd8, 0xb5, 0xcf, 0x71, 0x9b, 0x32, 0x6d, 0xaa, 0xaa, 0x5d, 0xe7, 0x6a, 0x59,
+	0xe1, 0x6e, 0x41, 0x10, 0xa4, 0x7d, 0xe8, 0xb6, 0x02, 0x43, 0x96, 0x06, 0x4e, 0xd6, 0x34, 0x33,
+	0x94, 0x20, 0x18, 0x06, 0xec, 0x41, 0xb3, 0xce, 0xb5, 0x11, 0x5b, 0xf4, 0x24, 0x2a, 0x69, 0xf6,
+	0x27, 0xf6, 0x03, 0xf6, 0x0b, 0xf6, 0xb2, 0x87, 0x0d, 0xfd, 0x33, 0xfb, 0x23, 0x7b, 0x1d, 0x48,
+	0x91, 0x32, 0x65, 0x49, 0x6e, 0xd0, 0x0c, 0x03, 0xfa, 0xa6, 0x3b, 0xde, 0x7d, 0xbc, 0xfb, 0x8e,
+	0x47, 0x9e, 0x0d, 0x4b, 0xaf, 0x69, 0xe0, 0xf6, 0xd9, 0xc6, 0x24, 0xa0, 0x8c, 0x92, 0x6a, 0x2c,
+	0xd9, 0x7f, 0x95, 0xe0, 0xd6, 0xf6, 0x64, 0x82, 0xbe, 0xb7, 0xeb, 0xb3, 0x60, 0x88, 0xa1, 0x83,
+	0x3f, 0x47, 0x18, 0x32, 0x42, 0x60, 0x91, 0x61, 0x30, 0x36, 0x8d, 0x96, 0xd1, 0x6e, 0x3a, 0xe2,
+	0x9b, 0x58, 0x50, 0x1b, 0xa1, 0xeb, 0x61, 0xb0, 0xef, 0x99, 0xa5, 0x96, 0xd1, 0xae, 0x3b, 0x89,
+	0x4c, 0x6c, 0x58, 0x9a, 0x04, 0x78, 0x76, 0x40, 0x5f, 0xef, 0xfb, 0x1e, 0xbe, 0x31, 0xcb, 0xc2,
+	0x2f, 0xa5, 0x23, 0x2d, 0x68, 0x48, 0xf9, 0x98, 0x43, 0x2f, 0x0a, 0x13, 0x5d, 0x45, 0x9e, 0xc3,
+	0x35, 0x8c, 0xe3, 0x30, 0x2b, 0xad, 0x72, 0xbb, 0xb1, 0x65, 0x6f, 0xc8, 0xb0, 0xf3, 0x82, 0xdc,
+	0xe0, 0xe2, 0x85, 0xa3, 0x5c, 0x78, 0x0c, 0x71, 0x3c, 0x3b, 0x74, 0x3c, 0x1e, 0x32, 0xb3, 0x1a,
+	0xc7, 0xa0, 0xeb, 0xac, 0x1d, 0xa8, 0x08, 0x2f, 0xb2, 0x0c, 0xe5, 0x53, 0xbc, 0x10, 0xf9, 0xd5,
+	0x1d, 0xfe, 0x49, 0x6e, 0x41, 0xe5, 0xcc, 0x1d, 0x45, 0x28, 0x73, 0x8b, 0x85, 0x84, 0x88, 0xf2,
+	0x94, 0x08, 0xfb, 0x57, 0x03, 0x6e, 0xcf, 0x04, 0x14, 0x4e, 0xa8, 0x1f, 0x62, 0x2e, 0x6d, 0x26,
+	0x5c, 0x0b, 0xa3, 0x5e, 0x0f, 0xc3, 0x50, 0x20, 0xd7, 0x1c, 0x25, 0xf2, 0x80, 0x7b, 0xd4, 0xef,
+	0x8f, 0x86, 0x3d, 0x76, 0x3c, 0xdd, 0x23, 0xa5, 0x23, 0x6b, 0xd0, 0x54, 0x72, 0xcc, 0x6c, 0x4c,
+	0x5b, 0x5a, 0x69, 0xff, 0x6d, 0x00, 0x91, 0xac, 0x9c, 0x50, 0x86, 0xf3, 0xaa, 0xd8, 0x82, 0x46,
+	0xcf, 0xf5, 0xbd, 0xa1, 0xe7, 0x32, 0x4c, 0x0a, 0xa9, 0xab, 0x04, 0x8f, 0x6e, 0xc8, 0x66, 0x6b,
+	0xa9, 0xeb, 0x38, 0x8a, 0x94, 0xf5, 0x5a, 0x6a, 0x2a, 0x9e, 0xf6, 0x24, 0x40, 0x1e, 0x8d, 0x59,
+	0x89, 0xd3, 0x96, 0x22, 0xd9, 0x00, 0x12, 0xd7, 0x24, 0x1c, 0x0c, 0x27, 0xc7, 0x81, 0xeb, 0x87,
+	0x7d, 0x0c, 0x44, 0xb5, 0x6a, 0x4e, 0xce, 0x8a, 0xfd, 0x12, 0x56, 0x52, 0xb9, 0xcd, 0xe1, 0xba,
+	0x05, 0x8d, 0x33, 0xca, 0xb0, 0x13, 0xb8, 0x3e, 0x43, 0x4f, 0xf2, 0xad, 0xab, 0xec, 0x1d, 0xb8,
+	0x79, 0x3c, 0x1c, 0x23, 0x8d, 0xd8, 0x21, 0x3d, 0x7f, 0xcf, 0xd3, 0x6e, 0xb7, 0x81, 0xe8, 0x20,
+	0xc5, 0x01, 0xd9, 0xff, 0x54, 0x60, 0x75, 0xdf, 0x0f, 0x99, 0x3b, 0x1a, 0x1d, 0xf9, 0xee, 0x24,
+	0x1c, 0x50, 0xf6, 0xbe, 0x2d, 0xb6, 0x0e, 0x37, 0x39, 0xbf, 0xfb, 0x7e, 0x6f, 0x14, 0x79, 0xe8,
+	0xe9, 0xb5, 0xc9, 0x2e, 0x90, 0xcf, 0x60, 0x59, 0x57, 0x6a, 0x55, 0xca, 0xe8, 0xc9, 0xd7, 0x50,
+	0xf1, 0xa9, 0x97, 0x34, 0xdd, 0x63, 0xd5, 0x74, 0xf9, 0x81, 0x6f, 0x1c, 0x72, 0xdb, 0xb8, 0xf7,
+	0x62, 0x3f, 0x9e, 0x4a, 0x38, 0xfc, 0x05, 0x45, 0x0d, 0x17, 0x1d, 0xf1, 0xcd, 0x53, 0xe9, 0x0d,
+	0xb0, 0x77, 0x1a, 0x46, 0x63, 0xf3, 0x9a, 0xd8, 0x38, 0x91, 0xc9, 0x2a, 0x54, 0x69, 0xbf, 0x1f,
+	0x22, 0x33, 0x6b, 0xc2, 0x43, 0x4a, 0x1c, 0xc7, 0x73, 0x99, 0x6b, 0xd6, 0x5b, 0x46, 0x7b, 0xc9,
+	0x11, 0xdf, 0xa2, 0x01, 0x06, 0x91, 0x7f, 0xba, 0xa3, 0xc0, 0x40, 0x36, 0x80, 0xae, 0x14, 0x9e,
+	0xd4, 0x47, 0xb3, 0x21, 0x2a, 0x2e, 0xbe, 0xc9, 0x1e, 0xd4, 0x7c, 0x3c, 0x17, 0xd1, 0x9a, 0x4b,
+	0x22, 0xb3, 0xf5, 0x77, 0x65, 0x86, 0xe7, 0x5a, 0x72, 0x89, 0x37, 0x47, 0x1a, 0xa1, 0x1b, 0xf8,
+	0x18, 0x84, 0x66, 0xf3, 0x52, 0x48, 0x07, 0xd2, 0x5c, 0x22, 0x29, 0x6f, 0xeb, 0x5b, 0x80, 0xe9,
+	0x0e, 0x39, 0x97, 0xd0, 0x23, 0xfd, 0x12, 0x6a, 0x6c, 0x2d, 0xab, 0x6d, 0xb8, 0xd3, 0x1e, 0x0d,
+	0x99, 0xbc, 0x96, 0xbe, 0x2c, 0x3d, 0x33, 0xac, 0x57, 0xd0, 0x4c, 0x05, 0x7c, 0x75, 0xb8, 0x54,
+	0xd4, 0x57, 0x83, 0xb3, 0x4f, 0xe1, 0x4e, 0x86, 0x9b, 0x39, 0x9d, 0xfb, 0x00, 0xc0, 0xc7, 0x37,
+	0xec, 0xbb, 0xf8, 0x58, 0x94, 0xc4, 0xb1, 0xd0, 0x34, 0xe4, 0x3e, 0xd4, 0x87, 0x31, 0x1c, 0x7a,
+	0xe2, 0xd4, 0xd7, 0x9c, 0xa9, 0xc2, 0xee, 0x42, 0x4d, 0xc5, 0xc0, 0xc3, 0x8e, 0x82, 0x91, 0x0a,
+	0x3b, 0x0a, 0x46, 0xfc, 0x2a, 0x72, 0x27, 0xc3, 0x2e, 0x0d, 0x62, 0xe0, 0xa6, 0xa3, 0x44, 0xbe,
+	0x12, 0x4c, 0x7a, 0x62, 0x25, 0xee, 0x24, 0x25, 0xda, 0x4f, 0x01, 0xba, 0x51, 0xd2, 0xab, 0x97,
+	0x7c, 0x2d, 0xec, 0x26, 0x34, 0xba, 0x51, 0x92, 0xa8, 0xfd, 0x00, 0xa0, 0x83, 0xc5, 0x20, 0xf6,
+	0x27, 0xd0, 0xe8, 0x60, 0x62, 0x3e, 0xc5, 0x34, 0x74, 0xcc, 0x87, 0xd0, 0x7c, 0x81, 0x23, 0x64,
+	0x58, 0x8c, 0xb3, 0x0c, 0xd7, 0x95, 0x89, 0xdc, 0x79, 0x00, 0xd7, 0xb7, 0x3d, 0x8f, 0x73, 0xa2,
+	0xbc, 0x56, 0xa1, 0xca, 0x9b, 0x75, 0xdf, 0x93, 0x8e, 0x52, 0x22, 0x6b, 0xb0, 0x38, 0xa0, 0x21,
+	0x2b, 0x2c, 0xa9, 0x58, 0xe5, 0x44, 0xc9, 0x33, 0x2c, 0xc9, 0x57, 0xa2, 0xfd, 0x7b, 0x09, 0x6e,
+	0x24, 0x5b, 0xc9, 0x44, 0x9e, 0xa9, 0x0b, 0xc5, 0x98, 0x79, 0xc5, 0xd3, 0x76, 0x39, 0x37, 0xc9,
+	0xb6, 0xd6, 0x69, 0x25, 0xe1, 0xfc, 0x69, 0x91, 0xf3, 0xff, 0xd4, 0x62, 0xff, 0x65, 0x4f, 0x7c,
+	0x0e, 0x37, 0x1d, 0x1c, 0xd3, 0x33, 0xbc, 0x44, 0x61, 0xec, 0x3f, 0x4b, 0x40, 0x74, 0x6b, 0xc9,
+	0xed, 0x57, 0x69, 0x6e, 0x13, 0x7a, 0xb2, 0xa6, 0x39, 0xf4, 0xbe, 0xc8, 0xd0, 0xdb, 0x9e, 0xe3,
+	0xff, 0x01, 0x32, 0xbc, 0x0e, 0xa4, 0x1b, 0xd0, 0x31, 0x65, 0x97, 0xa2, 0xf8, 0x6d, 0x09, 0x56,
+	0x52, 0xe6, 0x92, 0xe3, 0xe7, 0x69, 0x8e, 0x1f, 0xa9, 0x1d, 0x73, 0x6c, 0x73, 0x48, 0xde, 0xcd,
+	0x90, 0xfc, 0x78, 0x1e, 0xc0, 0x07, 0xc8, 0xf2, 0x13, 0xb8, 0xab, 0xa6, 0xb3, 0x83, 0x64, 0x5e,
+	0x7b, 0x17, 0xd9, 0x4f, 0xc1, 0xca, 0x73, 0x92, 0x94, 0x17, 0x79, 0xbd, 0x82, 0xfa, 0x21, 0x65,
+	0xb1, 0x43, 0x6a, 0x3c, 0x32, 0x66, 0xc6, 0xa3, 0x35, 0x68, 0xc6, 0xdf, 0xdb, 0x9e, 0x17, 0xa8,
+	0x61, 0xbb, 0xee, 0xa4, 0x95, 0x5b, 0xbf, 0x95, 0xa1, 0xda, 0xa1, 0x8e, 0xdb, 0x67, 0xe4, 0x10,
+	0x9a, 0xa9, 0x21, 0x9e, 0xdc, 0x9f, 0xf7, 0x63, 0xc3, 0xfa, 0xa8, 0x60, 0x55, 0x5e, 0xb8, 0x0b,
+	0xe4, 0x7b, 0x58, 0x49, 0x2d, 0x1d, 0xb1, 0x00, 0xdd, 0xf1, 0x15, 0x51, 0xdb, 0xc6, 0xa6, 0x41,
+	0xf6, 0xa0, 0xa1, 0x0d, 0xc0, 0xc4, 0x9a, 0xb6, 0xec, 0xec, 0xc4, 0x6f, 0xdd, 0xcb, 0x5d, 0x4b,
+	0x62, 0x3c, 0x81, 0x1b, 0x33, 0x8f, 0x32, 0x79, 0x30, 0x7f, 0x92, 0xb1, 0x3e, 0x2e, 0x5c, 0x9f,
+	0xc6, 0x48, 0x76, 0x01, 0xa6, 0x03, 0x31, 0xb9, 0xab, 0x5c, 0x32, 0x93, 0xb6, 0x65, 0xe5, 0x2d,
+	0x29, 0xa0, 0xad, 0x3f, 0x0c, 0x68, 0xbe, 0xc4, 0x8b, 0x13, 0x7e, 0xd0, 0x8e, 0x18, 0x0d, 0x90,
+	0x6c, 0x42, 0xb9, 0x1b, 0x31, 0x42, 0x92, 0x06, 0x4a, 0xde, 0x64, 0x6b, 0x25, 0xa5, 0x4b, 0x52,
+	0xdc, 0x84, 0x72, 0x07, 0x35, 0x8f, 0x0e, 0x66, 0x3d, 0xb4, 0x47, 0xd7, 0x5e, 0x20, 0x5f, 0x40,
+	0x35, 0x7e, 0x3d, 0xc9, 0x6d, 0x65, 0x90, 0x7a, 0x70, 0xad, 0xd5, 0x59, 0x75, 0x12, 0xf0, 0xdb,
+	0x12, 0x54, 0xb6, 0xbd, 0xf1, 0xd0, 0xe7, 0x3f, 0x5d, 0xe5, 0x03, 0x45, 0x56, 0x33, 0x2f, 0x56,
+	0x0c, 0x73, 0xa7, 0xe0, 0x25, 0xb3, 0x17, 0x38, 0x7f, 0xd3, 0xfb, 0x77, 0xca, 0x5f, 0xe6, 0xb1,
+	0xb0, 0xac, 0xe2, 0xeb, 0xda, 0x5e, 0xe0, 0x07, 0x45, 0xbb, 0x61, 0xa6, 0x07, 0x25, 0x7b, 0x25,
+	0x5a, 0xf7, 0x72, 0xd7, 0x12, 0xa4, 0x1f, 0x81, 0x64, 0x9b, 0x95, 0x3c, 0x4c, 0xaa, 0x57, 0xd4,
+	0xfd, 0x96, 0x3d, 0xcf, 0x44, 0xc1, 0x7f, 0x53, 0xf9, 0xa1, 0x1c, 0x4c, 0x7a, 0x3f, 0x55, 0xc5,
+	0xbf, 0x11, 0x4f, 0xfe, 0x1d, 0x00, 0xa7, 0x17, 0x5b, 0xf0, 0x9d, 0x10, 0x00, 0x00,
 }
@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// keyValueServer is used to implement the KeyValueStore gRPC server, which
+// lets clients read and write the storage state through the leader.
+type keyValueServer struct{}
+
+// Put stores the key-value pair, returning once the change has been committed
+// and applied to the storage state. Followers either forward the request to
+// the leader or fail it with a NotLeader error.
+func (s *keyValueServer) Put(ctx context.Context, request *PutRequest) (*PutResponse, error) {
+	if request.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	} else if state.IsReservedKey(request.Key) {
+		return nil, status.Error(codes.InvalidArgument, "key is reserved")
+	}
+
+	err := propose(ctx, request.Key, request.Value)
+	if address, ok := forwardingAddress(ctx, err); ok {
+		global.Log.Debug("Forwarding Put to leader at", address)
+		return SendPut(forwardedContext(ctx), address, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PutResponse{}, nil
+}
+
+// Get returns the value of the key in the storage state, or an empty string if
+// the key does not exist. The read reflects every write that was acknowledged
+// before it arrived, even if the node has been replaced as leader.
+func (s *keyValueServer) Get(ctx context.Context, request *GetRequest) (*GetResponse, error) {
+	readIndex, err := readIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for everything the leader had committed when the read arrived to
+	// be applied, so that the read reflects all the writes that have been
+	// acknowledged.
+	nodeState := state.GetNodeState()
+	if !nodeState.WaitForLastApplied(readIndex, ctx.Done()) {
+		return nil, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	}
+
+	value, err := nodeState.StorageDataStore.Get(request.Key)
+	if err != nil {
+		global.Log.Error("Failed to get value:", err.Error())
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &GetResponse{Value: value}, nil
+}
+
+// readIndex returns the leader's CommitIndex once a quorum has confirmed that
+// the node was still the leader after the read arrived, so that a deposed
+// leader can't serve a stale read. Concurrent reads share the confirmation.
+// A new leader first waits until it has committed an entry in its term, since
+// until then its CommitIndex may not include every committed entry. With
+// lease_reads enabled, the confirmation is skipped while the leader's lease is
+// valid.
+func readIndex(ctx context.Context) (uint32, error) {
+	nodeState := state.GetNodeState()
+
+	nodeState.Lock()
+	for {
+		if nodeState.Role() != state.Leader {
+			defer nodeState.Unlock()
+			return 0, notLeaderError(nodeState)
+		} else if nodeState.CommittedInTerm() {
+			break
+		}
+
+		commitIndexChanged := nodeState.CommitIndexChanged()
+		roleChanged := nodeState.RoleChanged()
+		nodeState.Unlock()
+		select {
+		case <-commitIndexChanged:
+		case <-roleChanged:
+		case <-ctx.Done():
+			return 0, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+		}
+		nodeState.Lock()
+	}
+
+	index := nodeState.CommitIndex
+	if global.Config.LeaseReads && nodeState.LeaseValid(global.Config.NodeId, global.LeaseDuration()) {
+		// No other leader can have been elected since the read arrived.
+		nodeState.Unlock()
+		return index, nil
+	}
+
+	term := nodeState.CurrentTerm()
+	round := nodeState.StartReadRound(global.Config.NodeId)
+	for {
+		if nodeState.Role() != state.Leader || nodeState.CurrentTerm() != term {
+			defer nodeState.Unlock()
+			return 0, notLeaderError(nodeState)
+		} else if nodeState.ConfirmedReadRound() >= round {
+			break
+		}
+
+		readRoundConfirmed := nodeState.ReadRoundConfirmed()
+		roleChanged := nodeState.RoleChanged()
+		nodeState.Unlock()
+		select {
+		case <-readRoundConfirmed:
+		case <-roleChanged:
+		case <-ctx.Done():
+			return 0, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+		}
+		nodeState.Lock()
+	}
+	nodeState.Unlock()
+
+	return index, nil
+}
+
+// Delete removes the key, returning once the change has been committed and
+// applied to the storage state. Deleted keys are stored with an empty value,
+// the same as keys that were never set. Followers either forward the request
+// to the leader or fail it with a NotLeader error.
+func (s *keyValueServer) Delete(ctx context.Context, request *DeleteRequest) (*DeleteResponse, error) {
+	if request.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	} else if state.IsReservedKey(request.Key) {
+		return nil, status.Error(codes.InvalidArgument, "key is reserved")
+	}
+
+	err := propose(ctx, request.Key, "")
+	if address, ok := forwardingAddress(ctx, err); ok {
+		global.Log.Debug("Forwarding Delete to leader at", address)
+		return SendDelete(forwardedContext(ctx), address, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{}, nil
+}
+
+// propose appends the key-value pair to the leader's log, batched with other
+// concurrent proposals, and waits until the entry has been applied to the
+// storage state.
+func propose(ctx context.Context, key string, value string) error {
+	proposal := &proposal{key: key, value: value, result: make(chan proposalResult, 1)}
+	select {
+	case batcher.proposals <- proposal:
+	case <-ctx.Done():
+		return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	}
+
+	var result proposalResult
+	select {
+	case result = <-proposal.result:
+	case <-ctx.Done():
+		return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	}
+	if result.err != nil {
+		return result.err
+	}
+	index, term := result.index, result.term
+
+	nodeState := state.GetNodeState()
+	if !nodeState.WaitForLastApplied(index, ctx.Done()) {
+		return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	}
+
+	// If a new leader replaced the entry before it was committed, the entry
+	// applied at the index is not the proposed one.
+	nodeState.Lock()
+	defer nodeState.Unlock()
+	if index < nodeState.LogOffset() {
+		// The entry was compacted into a snapshot from the new leader before
+		// its term could be checked.
+		return status.Error(codes.Unknown, "the outcome of the request could not be determined")
+	} else if nodeState.LogTerm(index) != term {
+		return status.Error(codes.Aborted, "leadership changed before the entry was committed")
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// newKeyValueStoreClient returns a KeyValueStoreClient connected to the test
+// server, along with a function that closes the connection.
+func newKeyValueStoreClient(t *testing.T) (KeyValueStoreClient, func()) {
+	conn, err := grpc.Dial("127.0.0.1:"+apiPort, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewKeyValueStoreClient(conn), func() { conn.Close() }
+}
+
+// becomeSingleNodeLeader makes the test node the leader of a cluster that
+// only contains itself, and applies committed entries until the returned
+// function is called, which waits for the applying to stop so that it can't
+// outlive the test.
+func becomeSingleNodeLeader() func() {
+	global.Config.NodeId = "1"
+	global.Config.Nodes = map[string]global.NodeHost{"1": {Url: "127.0.0.1"}}
+
+	state.Node.Lock()
+	state.Node.Bootstrap(global.Config.Nodes)
+	term, _ := state.Node.BecomeCandidate("1")
+	state.Node.BecomeLeader(term, "1", state.Node.Membership().NodeIds())
+	// Like a real leader, start the term with a committed no-op entry.
+	state.Node.AppendLeaderEntry("", "")
+	state.Node.AdvanceCommitIndex("1")
+	state.Node.Unlock()
+
+	return runInBackground(func(nodeState *state.NodeState) {
+		nodeState.ApplyCommittedEntries()
+	})
+}
+
+// runInBackground calls f with the test node's state every 10 milliseconds
+// until the returned function is called, which waits for the calls to stop so
+// that they can't outlive the test.
+func runInBackground(f func(nodeState *state.NodeState)) func() {
+	nodeState := state.Node
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		for {
+			f(nodeState)
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func Test_Put_WhenNotLeader_ReturnsNotLeaderWithLeaderAddress(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.NodeId = "1"
+	global.Config.ForwardToLeader = false
+	state.Node.Bootstrap(map[string]global.NodeHost{
+		"1": {Url: "127.0.0.1", ApiPort: 8000},
+		"2": {Url: "10.0.0.2", ApiPort: 8002},
+	})
+	state.Node.LeaderId = "2"
+
+	client, closeClient := newKeyValueStoreClient(t)
+	defer closeClient()
+
+	_, err := client.Put(context.Background(), &PutRequest{Key: "a", Value: "A"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Error("Error was not FailedPrecondition:", err)
+	}
+
+	notLeader, ok := LeaderHint(err)
+	if !ok {
+		t.Fatal("Error did not include a leader hint:", err)
+	}
+	if notLeader.LeaderId != "2" || notLeader.LeaderAddress != "10.0.0.2:8002" {
+		t.Error("Leader hint was not 2 at 10.0.0.2:8002:", notLeader)
+	}
+}
+
+func Test_Put_WhenForwardedByAnotherNode_DoesNotForwardAgain(t *testing.T) {
+	resetTestEnvironment()
+
+	// The node believes it is the leader, so forwarding would loop forever if
+	// forwarded requests were forwarded again.
+	global.Config.NodeId = "1"
+	state.Node.Bootstrap(map[string]global.NodeHost{"1": {Url: "127.0.0.1", ApiPort: 8000}})
+	global.Config.ForwardToLeader = true
+	defer func() { global.Config.ForwardToLeader = false }()
+	state.Node.LeaderId = "1"
+
+	client, closeClient := newKeyValueStoreClient(t)
+	defer closeClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Put(ctx, &PutRequest{Key: "a", Value: "A"})
+	if _, ok := LeaderHint(err); !ok {
+		t.Error("Error did not include a leader hint:", err)
+	}
+}
+
+func Test_PutGetDelete_WhenLeader_UpdatesAndReadsStorage(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+
+	client, closeClient := newKeyValueStoreClient(t)
+	defer closeClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.Put(ctx, &PutRequest{Key: "a", Value: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.Get(ctx, &GetRequest{Key: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Value != "A" {
+		t.Error("Value was not A:", response.Value)
+	}
+
+	_, err = client.Delete(ctx, &DeleteRequest{Key: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err = client.Get(ctx, &GetRequest{Key: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Value != "" {
+		t.Error("Value was not empty:", response.Value)
+	}
+}
+
+func Test_Get_WhenQuorumDoesNotConfirmLeadership_DoesNotServeRead(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.NodeId = "1"
+	state.Node.Lock()
+	state.Node.Bootstrap(map[string]global.NodeHost{"1": {Url: "127.0.0.1"}, "2": {Url: "10.0.0.2"}})
+	term, _ := state.Node.BecomeCandidate("1")
+	state.Node.BecomeLeader(term, "1", state.Node.Membership().NodeIds())
+	index, _, _ := state.Node.AppendLeaderEntry("", "")
+	state.Node.MatchIndex["2"] = index
+	state.Node.AdvanceCommitIndex("1")
+	state.Node.Unlock()
+	state.Node.ApplyCommittedEntries()
+
+	// The server is called directly so that the read has ended by the time
+	// the call returns, even when it times out.
+	server := &keyValueServer{}
+
+	// Node 2 never responds, so the leader may have been deposed.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := server.Get(ctx, &GetRequest{Key: "a"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Error("Error was not DeadlineExceeded:", err)
+	}
+
+	// Node 2 acknowledges every request sent from now on.
+	defer runInBackground(func(nodeState *state.NodeState) {
+		nodeState.Lock()
+		nodeState.AckReadRound("2", nodeState.ReadRoundForRequest(), "1")
+		nodeState.Unlock()
+	})()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = server.Get(ctx, &GetRequest{Key: "a"})
+	if err != nil {
+		t.Error("Read confirmed by a quorum failed:", err)
+	}
+}
+
+func Test_Get_WhenLeaseIsValid_ServesReadWithoutConfirmingLeadership(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.NodeId = "1"
+	global.Config.ElectionTimeout = 250
+	global.Config.ElectionTimeoutJitter = 100
+	global.Config.LeaseReads = true
+	defer func() { global.Config.LeaseReads = false }()
+	state.Node.Lock()
+	state.Node.Bootstrap(map[string]global.NodeHost{"1": {Url: "127.0.0.1"}, "2": {Url: "10.0.0.2"}})
+	term, _ := state.Node.BecomeCandidate("1")
+	state.Node.BecomeLeader(term, "1", state.Node.Membership().NodeIds())
+	index, _, _ := state.Node.AppendLeaderEntry("", "")
+	state.Node.MatchIndex["2"] = index
+	state.Node.AdvanceCommitIndex("1")
+	state.Node.AckLease("2", time.Now())
+	state.Node.Unlock()
+	state.Node.ApplyCommittedEntries()
+
+	// The server is called directly so that the read has ended before the
+	// config is reset, even when it times out.
+	server := &keyValueServer{}
+
+	// Node 2 never responds to another request, so only the lease allows
+	// the read.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := server.Get(ctx, &GetRequest{Key: "a"})
+	if err != nil {
+		t.Error("Read under a valid lease failed:", err)
+	}
+
+	// Once the lease expires, the read needs a quorum to confirm leadership.
+	time.Sleep(global.LeaseDuration())
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = server.Get(ctx, &GetRequest{Key: "a"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Error("Error was not DeadlineExceeded:", err)
+	}
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// Metadata key set on requests that a follower forwards to the leader, so that
+// they are never forwarded a second time.
+const forwardedKey string = "goraft-forwarded"
+
+// notLeaderError returns a FailedPrecondition error with a NotLeader detail
+// that tells the client which node the local node believes is the leader.
+// The node state lock must be held by the caller.
+func notLeaderError(nodeState *state.NodeState) error {
+	notLeader := &NotLeader{LeaderId: nodeState.LeaderId}
+	if host, ok := nodeState.Membership().Host(nodeState.LeaderId); ok {
+		notLeader.LeaderAddress = host.ApiAddress()
+	}
+
+	st, err := status.New(codes.FailedPrecondition, "node is not the leader").WithDetails(notLeader)
+	if err != nil {
+		global.Log.Error("Failed to add NotLeader details:", err.Error())
+		return status.Error(codes.FailedPrecondition, "node is not the leader")
+	}
+	return st.Err()
+}
+
+// LeaderHint returns the NotLeader details from an error returned by a client
+// request, or false if the error was not caused by the node not being the
+// leader.
+func LeaderHint(err error) (*NotLeader, bool) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.FailedPrecondition {
+		return nil, false
+	}
+
+	for _, detail := range st.Details() {
+		if notLeader, ok := detail.(*NotLeader); ok {
+			return notLeader, true
+		}
+	}
+	return nil, false
+}
+
+// forwardingAddress returns the address of the leader that a failed proposal
+// should be forwarded to, or false if it should not be forwarded. Proposals
+// are only forwarded when forward_to_leader is enabled, the leader is known,
+// and the request was not already forwarded by another node.
+func forwardingAddress(ctx context.Context, err error) (string, bool) {
+	if !global.Config.ForwardToLeader {
+		return "", false
+	}
+
+	notLeader, ok := LeaderHint(err)
+	if !ok || notLeader.LeaderAddress == "" {
+		return "", false
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[forwardedKey]) > 0 {
+		return "", false
+	}
+	return notLeader.LeaderAddress, true
+}
+
+// forwardedContext returns an outgoing context for forwarding a request to the
+// leader, marked so that the leader won't forward it again.
+func forwardedContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, forwardedKey, "true")
+}
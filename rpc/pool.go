@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// The longest that a broken connection waits between attempts to reconnect.
+const maxReconnectBackoff time.Duration = 5 * time.Second
+
+// ConnectionPool keeps a long-lived gRPC connection to each address that it
+// has been asked for, so that frequent requests such as heartbeats don't pay
+// for a new connection every time. Broken connections are reconnected in the
+// background with exponential backoff.
+type ConnectionPool struct {
+	mutex sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// Pool is the connection pool shared by all the requests the node sends.
+var Pool *ConnectionPool = NewConnectionPool()
+
+// NewConnectionPool returns an empty ConnectionPool.
+func NewConnectionPool() *ConnectionPool {
+	return &ConnectionPool{conns: make(map[string]*grpc.ClientConn)}
+}
+
+// Get returns the connection to the address, dialing it if the pool doesn't
+// have one yet. Dialing doesn't wait for the connection to be established.
+func (pool *ConnectionPool) Get(address string) (*grpc.ClientConn, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if conn, ok := pool.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address,
+		grpc.WithInsecure(),
+		grpc.WithBackoffMaxDelay(maxReconnectBackoff))
+	if err != nil {
+		return nil, err
+	}
+	pool.conns[address] = conn
+	return conn, nil
+}
+
+// Remove closes and removes the connection to the address, if there is one.
+func (pool *ConnectionPool) Remove(address string) error {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	conn, ok := pool.conns[address]
+	if !ok {
+		return nil
+	}
+	delete(pool.conns, address)
+	return conn.Close()
+}
+
+// Close closes and removes all the connections in the pool.
+func (pool *ConnectionPool) Close() error {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	var err error
+	for address, conn := range pool.conns {
+		if closeErr := conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(pool.conns, address)
+	}
+	return err
+}
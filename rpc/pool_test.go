@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"testing"
+)
+
+func Test_PoolGet_WithSameAddress_ReusesConnection(t *testing.T) {
+	pool := NewConnectionPool()
+	defer pool.Close()
+
+	first, err := pool.Get("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := pool.Get("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("Pool dialed a new connection for the same address")
+	}
+}
+
+func Test_PoolRemove_WithExistingAddress_DialsNewConnectionNextTime(t *testing.T) {
+	pool := NewConnectionPool()
+	defer pool.Close()
+
+	first, err := pool.Get("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pool.Remove("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := pool.Get("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Error("Pool reused a removed connection")
+	}
+}
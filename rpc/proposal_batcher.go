@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// The limits on a batch of proposals when proposal_batch_max_entries and
+// proposal_batch_max_bytes aren't configured. A batch fits in a single
+// AppendEntries request by default.
+const (
+	defaultBatchMaxEntries int = global.MaxEntriesPerRequest
+	defaultBatchMaxBytes   int = 1 << 20
+)
+
+// batchMaxEntries returns the most proposals that are appended in one batch.
+func batchMaxEntries() int {
+	if global.Config.ProposalBatchMaxEntries == 0 {
+		return defaultBatchMaxEntries
+	}
+	return int(global.Config.ProposalBatchMaxEntries)
+}
+
+// batchMaxBytes returns the most bytes of keys and values that are appended
+// in one batch, although a single proposal may exceed it.
+func batchMaxBytes() int {
+	if global.Config.ProposalBatchMaxBytes == 0 {
+		return defaultBatchMaxBytes
+	}
+	return int(global.Config.ProposalBatchMaxBytes)
+}
+
+// proposal is a key-value pair waiting to be appended to the leader's log.
+type proposal struct {
+	key   string
+	value string
+
+	// The outcome of appending the proposal, which is sent once.
+	result chan proposalResult
+}
+
+// proposalResult is the index and term that a proposal was appended at, or
+// the error that kept it from being appended.
+type proposalResult struct {
+	index uint32
+	term  uint32
+	err   error
+}
+
+// proposalBatcher gathers concurrent proposals and appends them to the
+// leader's log together, so that they share a single write to the data store
+// and a single AppendEntries request to each follower.
+type proposalBatcher struct {
+	proposals chan *proposal
+}
+
+// batcher batches the proposals made to this node.
+var batcher = &proposalBatcher{proposals: make(chan *proposal, defaultBatchMaxEntries)}
+
+// run appends batches of proposals for as long as the node runs. A batch is
+// appended once proposal_batch_window has passed since its first proposal, or
+// sooner if it reaches the size limits.
+func (batcher *proposalBatcher) run() {
+	for {
+		first := <-batcher.proposals
+		batch := []*proposal{first}
+		size := len(first.key) + len(first.value)
+
+		window := time.NewTimer(time.Duration(global.Config.ProposalBatchWindow) * time.Millisecond)
+	gather:
+		for len(batch) < batchMaxEntries() && size < batchMaxBytes() {
+			var next *proposal
+			select {
+			case next = <-batcher.proposals:
+			default:
+				// Proposals that are already waiting join the batch even
+				// once the window has passed.
+				select {
+				case next = <-batcher.proposals:
+				case <-window.C:
+					break gather
+				}
+			}
+			batch = append(batch, next)
+			size += len(next.key) + len(next.value)
+		}
+		window.Stop()
+
+		batcher.append(batch)
+	}
+}
+
+// append appends the batch to the leader's log and sends each proposal its
+// result.
+func (batcher *proposalBatcher) append(batch []*proposal) {
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+
+	var err error
+	var index, term uint32
+	if nodeState.TransferringLeadership() {
+		// The client will find the new leader once the transfer is done.
+		err = status.Error(codes.Unavailable, "leadership transfer in progress")
+	} else {
+		entries := make([]state.LogEntry, len(batch))
+		for i, proposal := range batch {
+			entries[i] = state.LogEntry{Key: proposal.key, Value: proposal.value}
+		}
+
+		var ok bool
+		index, term, ok = nodeState.AppendLeaderEntries(entries)
+		if ok {
+			// A single node cluster commits the entries right away.
+			nodeState.AdvanceCommitIndex(global.Config.NodeId)
+		} else {
+			err = notLeaderError(nodeState)
+		}
+	}
+	nodeState.Unlock()
+
+	for i, proposal := range batch {
+		if err != nil {
+			proposal.result <- proposalResult{err: err}
+		} else {
+			proposal.result <- proposalResult{index: index + uint32(i), term: term}
+		}
+	}
+}
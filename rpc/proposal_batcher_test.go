@@ -0,0 +1,108 @@
+package rpc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+func Test_append_WhenLeader_AppendsBatchAtConsecutiveIndices(t *testing.T) {
+	resetTestEnvironment()
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+
+	var batch []*proposal
+	for _, value := range []string{"a", "b", "c"} {
+		batch = append(batch, &proposal{key: "k", value: value, result: make(chan proposalResult, 1)})
+	}
+	(&proposalBatcher{}).append(batch)
+
+	// The batch follows the leader's no-op entry.
+	for i, proposal := range batch {
+		result := <-proposal.result
+		if result.err != nil {
+			t.Fatal(result.err)
+		}
+		if result.index != uint32(i+2) {
+			t.Errorf("Index of proposal %d was not %d: %d", i, i+2, result.index)
+		}
+	}
+
+	state.Node.Lock()
+	defer state.Node.Unlock()
+	if state.Node.LogLength() != 4 || state.Node.Log(4).Value != "c" {
+		t.Error("Batch was not appended in order:", state.Node.LogLength())
+	}
+}
+
+// countingDataStore counts the transactions that write log entries.
+type countingDataStore struct {
+	state.MemoryDataStore
+	sync.Mutex
+	putAllCalls int
+}
+
+func (store *countingDataStore) PutAll(values map[string]string) error {
+	store.Lock()
+	store.putAllCalls++
+	store.Unlock()
+	return store.MemoryDataStore.PutAll(values)
+}
+
+func (store *countingDataStore) calls() int {
+	store.Lock()
+	defer store.Unlock()
+	return store.putAllCalls
+}
+
+func Test_Put_WithConcurrentRequests_AppliesAllRequestsInFewerBatches(t *testing.T) {
+	resetTestEnvironment()
+	logStore := &countingDataStore{MemoryDataStore: state.NewMemoryDataStore()}
+	state.Node = state.NewNodeState(logStore, state.NewMemoryDataStore(), state.NewMemorySnapshotStore())
+
+	stop := becomeSingleNodeLeader()
+	defer stop()
+	callsBeforePuts := logStore.calls()
+	global.Config.ProposalBatchWindow = 50
+	defer func() { global.Config.ProposalBatchWindow = 0 }()
+
+	client, closeClient := newKeyValueStoreClient(t)
+	defer closeClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			_, err := client.Put(ctx, &PutRequest{Key: key, Value: key})
+			if err != nil {
+				t.Error(err)
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if batches := logStore.calls() - callsBeforePuts; batches >= len(keys) {
+		t.Errorf("%d concurrent Puts were appended in %d batches", len(keys), batches)
+	}
+
+	for _, key := range keys {
+		response, err := client.Get(ctx, &GetRequest{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Value != key {
+			t.Errorf("Value of %s was not %s: %s", key, key, response.Value)
+		}
+	}
+}
@@ -9,7 +9,12 @@ import (
 	"github.com/thomasylee/GoRaft/state"
 )
 
-const port string = "8000"
+// Ports for the test servers. Peer RPCs are sent to port, and client
+// requests are sent to apiPort.
+const (
+	port    string = "9000"
+	apiPort string = "8000"
+)
 
 func TestMain(m *testing.M) {
 	global.SetUpLogger()
@@ -17,10 +22,11 @@ func TestMain(m *testing.M) {
 
 	global.TimeoutChannel = make(chan bool, 1)
 
-	go RunServer(port)
-	// Give the server a few seconds to start.
+	go RunRpcServer(port)
+	go RunApiServer(apiPort)
+	// Give the servers a few seconds to start.
 	time.Sleep(3 * time.Second)
-	global.Log.Debug("gRPC server for tests has been started.")
+	global.Log.Debug("gRPC servers for tests have been started.")
 
 	os.Exit(m.Run())
 }
@@ -29,9 +35,14 @@ func resetTestEnvironment() {
 	close(global.TimeoutChannel)
 	global.TimeoutChannel = make(chan bool, 1)
 
+	receiver.Lock()
+	receiver.reset()
+	receiver.Unlock()
+
 	state.Node = state.NewNodeState(
 		state.NewMemoryDataStore(),
-		state.NewMemoryDataStore())
+		state.NewMemoryDataStore(),
+		state.NewMemorySnapshotStore())
 }
 
 func Test_AppendEntries_WhenRequestHasNoEntries_ReturnsSuccessTrue(t *testing.T) {
@@ -57,6 +68,37 @@ func Test_AppendEntries_WhenRequestHasNoEntries_ReturnsSuccessTrue(t *testing.T)
 	}
 }
 
+func Test_AppendEntries_WhenTermIsNewer_UpdatesTermAndClearsVote(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(1)
+	state.Node.SetVotedFor("1")
+
+	request := &AppendEntriesRequest{
+		Term:         2,
+		LeaderId:     "2",
+		PrevLogIndex: 0,
+		PrevLogTerm:  0,
+		Entries:      []*AppendEntriesRequest_Entry{},
+		LeaderCommit: 0,
+	}
+
+	response, err := SendAppendEntries("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Term != 2 || state.Node.CurrentTerm() != 2 {
+		t.Error("Term was not 2:", response.Term, state.Node.CurrentTerm())
+	}
+	if state.Node.VotedFor() != "" {
+		t.Error("Vote from the older term was kept:", state.Node.VotedFor())
+	}
+	if state.Node.LeaderId != "2" {
+		t.Error("LeaderId was not 2:", state.Node.LeaderId)
+	}
+}
+
 func Test_AppendEntries_WhenTermIsOlderThanCurrentTerm_ReturnsSuccessFalse(t *testing.T) {
 	resetTestEnvironment()
 
@@ -128,6 +170,89 @@ func Test_AppendEntries_WhenLogAtPrevLogIndexDoesNotMatch_ReturnsSuccessFalse(t
 	}
 }
 
+func Test_AppendEntries_WhenLogDoesNotMatch_ReturnsConflictHints(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(3)
+	state.Node.SetLogEntry(1, state.LogEntry{Term: 1, Key: "a", Value: "A"})
+	state.Node.SetLogEntry(2, state.LogEntry{Term: 2, Key: "b", Value: "B"})
+	state.Node.SetLogEntry(3, state.LogEntry{Term: 2, Key: "c", Value: "C"})
+	state.Node.SetLogEntry(4, state.LogEntry{Term: 2, Key: "d", Value: "D"})
+
+	var tests = []struct {
+		prevLogIndex  uint32
+		prevLogTerm   uint32
+		conflictTerm  uint32
+		conflictIndex uint32
+	}{
+		// The entry at index 4 has term 2, which starts at index 2.
+		{4, 3, 2, 2},
+		// The log ends at index 4.
+		{7, 3, 0, 4},
+	}
+
+	for _, test := range tests {
+		request := &AppendEntriesRequest{
+			Term:         3,
+			LeaderId:     "123",
+			PrevLogIndex: test.prevLogIndex,
+			PrevLogTerm:  test.prevLogTerm,
+		}
+
+		response, err := SendAppendEntries("127.0.0.1:"+port, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Success {
+			t.Errorf("Success was true for PrevLogIndex %d", test.prevLogIndex)
+		}
+		if response.ConflictTerm != test.conflictTerm || response.ConflictIndex != test.conflictIndex {
+			t.Errorf("Conflict hints for PrevLogIndex %d were not %d and %d: %d and %d", test.prevLogIndex,
+				test.conflictTerm, test.conflictIndex, response.ConflictTerm, response.ConflictIndex)
+		}
+	}
+}
+
+func Test_AppendEntriesStream_WithSeveralRequests_AnswersInOrder(t *testing.T) {
+	resetTestEnvironment()
+
+	stream, err := OpenAppendEntriesStream("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	requests := []*AppendEntriesRequest{
+		{Term: 1, LeaderId: "123", Entries: []*AppendEntriesRequest_Entry{{Key: "a", Value: "A", Term: 1}}},
+		{Term: 1, LeaderId: "123", PrevLogIndex: 1, PrevLogTerm: 1, Entries: []*AppendEntriesRequest_Entry{{Key: "b", Value: "B", Term: 1}}},
+		// The log doesn't have an entry at index 5.
+		{Term: 1, LeaderId: "123", PrevLogIndex: 5, PrevLogTerm: 1},
+	}
+	for _, request := range requests {
+		err = stream.Send(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, success := range []bool{true, true, false} {
+		response, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Success != success {
+			t.Errorf("Success for request %d was not %t", i, success)
+		}
+	}
+
+	state.Node.Lock()
+	defer state.Node.Unlock()
+	if state.Node.LogLength() != 2 || state.Node.Log(2).Key != "b" {
+		t.Error("Entries were not appended in order:", state.Node.LogLength())
+	}
+}
+
 func Test_AppendEntries_WhenLeaderCommitIsGreaterThanCommitIndex_IncreasesCommitIndex(t *testing.T) {
 	resetTestEnvironment()
 
@@ -172,8 +297,6 @@ func Test_AppendEntries_WhenLeaderCommitIsGreaterThanCommitIndex_IncreasesCommit
 func Test_RequestVote(t *testing.T) {
 	resetTestEnvironment()
 
-	state.Node.SetVotedFor("1")
-
 	request := &AppendEntriesRequest{
 		Term:         1,
 		LeaderId:     "123",
@@ -193,6 +316,10 @@ func Test_RequestVote(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// The vote is cast in the leader's term, since the newer term cleared any
+	// earlier vote.
+	state.Node.SetVotedFor("1")
+
 	var tests = []struct {
 		request  RequestVoteRequest
 		response RequestVoteResponse
@@ -277,3 +404,207 @@ func Test_RequestVote(t *testing.T) {
 		}
 	}
 }
+
+func Test_RequestVote_WhenLogIsEmptyAndTermIsNewer_GrantsVoteAndUpdatesTerm(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(1)
+	state.Node.SetVotedFor("1")
+
+	request := &RequestVoteRequest{
+		Term:         2,
+		CandidateId:  "2",
+		LastLogIndex: 0,
+		LastLogTerm:  0,
+	}
+
+	response, err := SendRequestVote("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.VoteGranted {
+		t.Error("VoteGranted was false")
+	}
+
+	if state.Node.CurrentTerm() != 2 {
+		t.Error("CurrentTerm was not 2:", state.Node.CurrentTerm())
+	}
+
+	if state.Node.VotedFor() != "2" {
+		t.Error("VotedFor was not 2:", state.Node.VotedFor())
+	}
+}
+
+func Test_RequestVote_WhenPreVote_GrantsVoteWithoutChangingTermOrVote(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(1)
+	state.Node.SetVotedFor("1")
+
+	request := &RequestVoteRequest{
+		Term:         2,
+		CandidateId:  "2",
+		LastLogIndex: 0,
+		LastLogTerm:  0,
+		PreVote:      true,
+	}
+
+	response, err := SendRequestVote("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.VoteGranted {
+		t.Error("VoteGranted was false")
+	}
+
+	if state.Node.CurrentTerm() != 1 {
+		t.Error("CurrentTerm was not 1:", state.Node.CurrentTerm())
+	}
+
+	if state.Node.VotedFor() != "1" {
+		t.Error("VotedFor was not 1:", state.Node.VotedFor())
+	}
+}
+
+func Test_RequestVote_WhenPreVoteAndLeaderWasHeardFrom_RejectsVote(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.ElectionTimeout = 250
+	global.Config.ElectionTimeoutJitter = 100
+	state.Node.SetCurrentTerm(1)
+	state.Node.BecomeFollower("1")
+
+	request := &RequestVoteRequest{
+		Term:         2,
+		CandidateId:  "2",
+		LastLogIndex: 0,
+		LastLogTerm:  0,
+		PreVote:      true,
+	}
+
+	response, err := SendRequestVote("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.VoteGranted {
+		t.Error("VoteGranted was true")
+	}
+
+	if response.Term != 1 || state.Node.CurrentTerm() != 1 {
+		t.Error("Term was not 1:", response.Term, state.Node.CurrentTerm())
+	}
+}
+
+func Test_RequestVote_WhenLeaseReadsAndLeaderWasHeardFrom_RejectsVoteUnlessTransfer(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.ElectionTimeout = 250
+	global.Config.ElectionTimeoutJitter = 100
+	global.Config.LeaseReads = true
+	defer func() { global.Config.LeaseReads = false }()
+	state.Node.SetCurrentTerm(1)
+	state.Node.BecomeFollower("1")
+
+	request := &RequestVoteRequest{
+		Term:         2,
+		CandidateId:  "2",
+		LastLogIndex: 0,
+		LastLogTerm:  0,
+	}
+
+	response, err := SendRequestVote("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.VoteGranted {
+		t.Error("VoteGranted was true while the leader's lease may be valid")
+	}
+	if response.Term != 1 || state.Node.CurrentTerm() != 1 {
+		t.Error("Term was not 1:", response.Term, state.Node.CurrentTerm())
+	}
+
+	request.LeadershipTransfer = true
+	response, err = SendRequestVote("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.VoteGranted {
+		t.Error("Vote was not granted for a leadership transfer")
+	}
+	if response.Term != 2 || state.Node.CurrentTerm() != 2 {
+		t.Error("Term was not 2:", response.Term, state.Node.CurrentTerm())
+	}
+}
+
+func Test_TimeoutNow_WhenFollowerIsVoter_StartsElection(t *testing.T) {
+	resetTestEnvironment()
+
+	global.Config.NodeId = "1"
+	state.Node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}})
+	state.Node.SetCurrentTerm(2)
+	state.Node.BecomeFollower("2")
+
+	response, err := SendTimeoutNow("127.0.0.1:"+port, &TimeoutNowRequest{Term: 1, LeaderId: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Term != 2 || state.Node.Role() != state.Follower {
+		t.Error("Stale TimeoutNow started an election:", response.Term, state.Node.Role())
+	}
+
+	response, err = SendTimeoutNow("127.0.0.1:"+port, &TimeoutNowRequest{Term: 2, LeaderId: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Term != 3 || state.Node.CurrentTerm() != 3 {
+		t.Error("Term was not 3:", response.Term, state.Node.CurrentTerm())
+	}
+	if state.Node.Role() != state.Candidate || state.Node.VotedFor() != "1" {
+		t.Error("Node did not become a candidate:", state.Node.Role(), state.Node.VotedFor())
+	}
+}
+
+func Test_AppendEntries_WhenEntryConflictsWithLog_ReplacesConflictingEntries(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(2)
+	state.Node.SetLogEntry(1, state.LogEntry{Term: 1, Key: "a", Value: "A"})
+	state.Node.SetLogEntry(2, state.LogEntry{Term: 1, Key: "b", Value: "B"})
+	state.Node.SetLogEntry(3, state.LogEntry{Term: 1, Key: "c", Value: "C"})
+
+	request := &AppendEntriesRequest{
+		Term:         2,
+		LeaderId:     "123",
+		PrevLogIndex: 1,
+		PrevLogTerm:  1,
+		Entries: []*AppendEntriesRequest_Entry{
+			&AppendEntriesRequest_Entry{
+				Key:   "d",
+				Value: "D",
+				Term:  2,
+			},
+		},
+		LeaderCommit: 0,
+	}
+
+	response, err := SendAppendEntries("127.0.0.1:"+port, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Success {
+		t.Error("Success was false")
+	}
+
+	if state.Node.LogLength() != 2 {
+		t.Fatal("LogLength was not 2:", state.Node.LogLength())
+	}
+
+	expected := state.LogEntry{Term: 2, Key: "d", Value: "D"}
+	if state.Node.Log(2) != expected {
+		t.Errorf("Log entry 2 was not %v: %v", expected, state.Node.Log(2))
+	}
+}
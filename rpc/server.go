@@ -1,8 +1,8 @@
 package rpc
 
 import (
+	"io"
 	"net"
-	"strconv"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -18,15 +18,38 @@ type server struct{}
 // AppendEntries adds the entries to the node state's log and updates other
 // attributes in the node state as necessary.
 func (s *server) AppendEntries(ctx context.Context, request *AppendEntriesRequest) (*AppendEntriesResponse, error) {
-	// Indicate that a message has been received so we don't time out.
-	global.TimeoutChannel <- true
+	return appendEntries(request)
+}
+
+// AppendEntriesStream handles the AppendEntries requests that the leader sends
+// over a long-lived stream, sending the responses in the order the requests
+// were received.
+func (s *server) AppendEntriesStream(stream GoRaft_AppendEntriesStreamServer) error {
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
 
-	// If the entries attribute is empty, it's just a heartbeat.
-	if len(request.Entries) == 0 {
-		return &AppendEntriesResponse{Term: state.Node.CurrentTerm(), Success: true}, nil
+		response, err := appendEntries(request)
+		if err != nil {
+			return err
+		}
+		err = stream.Send(response)
+		if err != nil {
+			return err
+		}
 	}
+}
 
+// appendEntries handles an AppendEntries request sent with either RPC.
+func appendEntries(request *AppendEntriesRequest) (*AppendEntriesResponse, error) {
 	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
 	response := &AppendEntriesResponse{
 		Term:    nodeState.CurrentTerm(),
 		Success: false,
@@ -38,92 +61,117 @@ func (s *server) AppendEntries(ctx context.Context, request *AppendEntriesReques
 	if request.Term < response.Term {
 		global.Log.Debug("success = false due to term being too old:", request.Term)
 		return response, nil
-	} else if request.Term > response.Term {
-		// Update CurrentTerm if the supplied term is newer.
+	} else if nodeState.StepDown(request.Term) {
+		// A newer term means the vote cast for the current term no longer
+		// applies.
 		response.Term = request.Term
-		nodeState.SetCurrentTerm(response.Term)
 	}
 
+	// The request is from the leader of the current term, so candidates step
+	// down. Indicate that a message has been received from the leader so we
+	// don't time out.
+	nodeState.BecomeFollower(request.LeaderId)
+	global.ResetTimeout()
+
 	global.Log.Debug("LogLength =", logLength)
 	if prevLogIndex > logLength {
 		global.Log.Debug("success = false due to PrevLogIndex > log length:", request.PrevLogIndex, nodeState.LogLength())
+		response.ConflictIndex = logLength
 		return response, nil
 	}
 
 	// Make sure PrevLogTerm matches the term of the entry at PrevLogIndex, unless
-	// the request considers these the first entries added to the log.
+	// the entry was removed by log compaction, in which case it was committed
+	// and must match the leader's log.
 	global.Log.Debug("PrevLogIndex =", prevLogIndex)
-	if prevLogIndex > 0 && request.PrevLogTerm != nodeState.Log(prevLogIndex).Term {
-
+	if prevLogIndex >= nodeState.LogOffset() && request.PrevLogTerm != nodeState.LogTerm(prevLogIndex) {
 		global.Log.Debug("success = false due to PrevLogIndex mismatch:", prevLogIndex)
+		// Tell the leader where the conflicting term starts, so that it can
+		// skip all of its entries at once.
+		response.ConflictTerm = nodeState.LogTerm(prevLogIndex)
+		response.ConflictIndex = nodeState.TermStartIndex(prevLogIndex)
 		return response, nil
 	}
 
-	requestEntriesIndex := 0
-	response.Success = true
-	var err error
-
 	// Save all the log entries that were received, but trust that ones with the
-	// same term don't need to be updated.
-	for i := prevLogIndex + 1; i <= prevLogIndex+uint32(len(request.Entries)); i++ {
-		if nodeState.LogLength() < i || nodeState.Log(i).Term != response.Term {
-			entry := request.Entries[requestEntriesIndex]
-			logEntry := state.LogEntry{
-				Key:   entry.Key,
-				Value: entry.Value,
-				Term:  response.Term,
+	// same term don't need to be updated. An entry with a different term
+	// conflicts with the leader's log, so it and all the entries after it are
+	// removed before saving the new entry. Entries covered by a snapshot are
+	// already committed, so they are skipped. The new entries are written in
+	// a single batch.
+	var firstNewIndex uint32
+	var newEntries []state.LogEntry
+	for i, entry := range request.Entries {
+		index := prevLogIndex + uint32(i) + 1
+		if index <= nodeState.LogOffset() {
+			continue
+		} else if index <= nodeState.LogLength() {
+			if nodeState.Log(index).Term == entry.Term {
+				continue
 			}
-
-			err = nodeState.SetLogEntry(i, logEntry)
+			err := nodeState.TruncateLog(index)
 			if err != nil {
 				global.Log.Error(err.Error())
-				response.Success = false
+				return response, err
 			}
 		}
-		requestEntriesIndex += 1
-	}
 
-	if !response.Success {
-		return response, err
+		if len(newEntries) == 0 {
+			firstNewIndex = index
+		}
+		newEntries = append(newEntries, state.LogEntry{
+			Key:   entry.Key,
+			Value: entry.Value,
+			Term:  entry.Term,
+		})
 	}
-
-	// Remove all log entries that existed in this node's state past the last
-	// index given by the request.
-	for i := prevLogIndex + uint32(len(request.Entries)) + 1; ; i++ {
-		key := strconv.Itoa(int(i))
-		value, err := nodeState.NodeDataStore.Get(key)
+	if len(newEntries) > 0 {
+		err := nodeState.SetLogEntries(firstNewIndex, newEntries)
 		if err != nil {
 			global.Log.Error(err.Error())
-			break
-		}
-		if value == "" {
-			break
+			return response, err
 		}
-		nodeState.NodeDataStore.Put(key, "")
 	}
 
-	// Update the leader id.
-	nodeState.LeaderId = request.LeaderId
-
 	// Update the node's commitIndex when the request's LeaderCommit index is
-	// higher.
-	if request.LeaderCommit > nodeState.CommitIndex {
-		if request.LeaderCommit > nodeState.LogLength() {
-			nodeState.CommitIndex = nodeState.LogLength() - 1
-		} else {
-			nodeState.CommitIndex = request.LeaderCommit
-		}
+	// higher, but never past the last entry sent by the leader since entries
+	// after it may not match the leader's log.
+	commitIndex := request.LeaderCommit
+	if lastNewIndex := prevLogIndex + uint32(len(request.Entries)); commitIndex > lastNewIndex {
+		commitIndex = lastNewIndex
+	}
+	if commitIndex > nodeState.CommitIndex {
+		nodeState.SetCommitIndex(commitIndex)
 	}
 
+	response.Success = true
 	global.Log.Debug("success = true")
-	return response, err
+	return response, nil
 }
 
 // RequestVote requests a vote for the node as the new leader.
 func (s *server) RequestVote(ctx context.Context, request *RequestVoteRequest) (*RequestVoteResponse, error) {
 	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
 	var err error
 
+	if request.PreVote {
+		return preVote(nodeState, request), err
+	}
+
+	// With lease reads, the leader may be serving reads without contacting
+	// the other nodes, so the vote is refused without changing the term
+	// while the node has heard from the leader within the minimum election
+	// timeout, unless the leader is handing over leadership.
+	if global.Config.LeaseReads && !request.LeadershipTransfer &&
+		nodeState.HeardFromLeader(global.MinElectionTimeout()) {
+		return &RequestVoteResponse{Term: nodeState.CurrentTerm(), VoteGranted: false}, err
+	}
+
+	// A newer term means the vote cast for the current term no longer applies.
+	nodeState.StepDown(request.Term)
+
 	response := &RequestVoteResponse{
 		Term:        nodeState.CurrentTerm(),
 		VoteGranted: false,
@@ -139,30 +187,132 @@ func (s *server) RequestVote(ctx context.Context, request *RequestVoteRequest) (
 		return response, err
 	}
 
-	// The candidate's log is out of date.
-	if request.LastLogIndex < nodeState.LogLength() {
-		return response, err
-	}
-
-	// The candidate's log disagrees with this node's log.
-	if request.LastLogTerm != nodeState.Log(request.LastLogIndex).Term {
+	if !candidateLogIsCurrent(nodeState, request) {
 		return response, err
 	}
 
+	nodeState.SetVotedFor(request.CandidateId)
 	response.VoteGranted = true
 
+	// Granting a vote means an election is underway, so don't time out.
+	global.ResetTimeout()
+
 	return response, err
 }
 
-// RunServer runs the RPC server on the port configured in config.yaml.
-func RunServer(port string) {
+// preVote responds to a pre-vote without changing the node's term or vote.
+// The vote would be granted if the candidate's log is current and the node
+// hasn't heard from a leader recently, so a node that was partitioned from a
+// healthy leader can't force an election when it reconnects.
+func preVote(nodeState *state.NodeState, request *RequestVoteRequest) *RequestVoteResponse {
+	response := &RequestVoteResponse{
+		Term:        nodeState.CurrentTerm(),
+		VoteGranted: false,
+	}
+
+	if request.Term <= nodeState.CurrentTerm() {
+		return response
+	} else if nodeState.HeardFromLeader(global.MinElectionTimeout()) {
+		return response
+	}
+
+	response.VoteGranted = candidateLogIsCurrent(nodeState, request)
+	return response
+}
+
+// candidateLogIsCurrent returns true if the candidate's log is at least as up
+// to date as the node's log.
+func candidateLogIsCurrent(nodeState *state.NodeState, request *RequestVoteRequest) bool {
+	// The candidate's log can't contain entries from a term newer than its own.
+	if request.LastLogTerm > request.Term {
+		return false
+	}
+
+	lastLogTerm := nodeState.LastLogTerm()
+	return request.LastLogTerm > lastLogTerm ||
+		(request.LastLogTerm == lastLogTerm && request.LastLogIndex >= nodeState.LogLength())
+}
+
+// TimeoutNow makes the node start an election right away, skipping the
+// election timeout and the pre-vote, because the leader is transferring
+// leadership to it.
+func (s *server) TimeoutNow(ctx context.Context, request *TimeoutNowRequest) (*TimeoutNowResponse, error) {
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	nodeState.StepDown(request.Term)
+	response := &TimeoutNowResponse{Term: nodeState.CurrentTerm()}
+
+	// Ignore requests from stale leaders, and nodes that can't vote can't
+	// become leader.
+	if request.Term < nodeState.CurrentTerm() || !nodeState.Membership().IsVoter(global.Config.NodeId) {
+		return response, nil
+	}
+
+	term, ok := nodeState.BecomeTransferCandidate(global.Config.NodeId)
+	if ok {
+		global.Log.Infof("Starting election for term %d at the request of %s", term, request.LeaderId)
+		response.Term = term
+
+		// Wake the node loop so that it runs the election.
+		global.ResetTimeout()
+	}
+	return response, nil
+}
+
+// InstallSnapshot receives a snapshot streamed in chunks by the leader and
+// installs it once all of it has been received and verified.
+func (s *server) InstallSnapshot(stream GoRaft_InstallSnapshotServer) error {
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			// The leader stopped before sending the last chunk.
+			return stream.SendAndClose(receiver.progress())
+		} else if err != nil {
+			// Keep what was received so that the transfer can be resumed.
+			global.Log.Debug("Snapshot stream failed:", err.Error())
+			return err
+		}
+
+		response, err := receiver.receive(request)
+		if err != nil {
+			global.Log.Error("Failed to receive snapshot:", err.Error())
+			return err
+		} else if response != nil {
+			return stream.SendAndClose(response)
+		}
+	}
+}
+
+// RunRpcServer runs the server for RPCs from other nodes in the cluster on the
+// given port, which should be the rpc_port configured in config.yaml.
+func RunRpcServer(port string) {
+	runServer(port, func(s *grpc.Server) {
+		RegisterGoRaftServer(s, &server{})
+	})
+}
+
+// RunApiServer runs the server for client and admin requests on the given
+// port, which should be the api_port configured in config.yaml.
+func RunApiServer(port string) {
+	go batcher.run()
+	runServer(port, func(s *grpc.Server) {
+		RegisterKeyValueStoreServer(s, &keyValueServer{})
+		RegisterAdminServer(s, &adminServer{})
+	})
+}
+
+// runServer runs a gRPC server on the given port with the services added by
+// the register function.
+func runServer(port string, register func(*grpc.Server)) {
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		global.Log.Panicf("Failed to listen: %v", err)
 	}
 
 	s := grpc.NewServer()
-	RegisterGoRaftServer(s, &server{})
+	register(s)
 
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
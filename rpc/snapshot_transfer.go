@@ -0,0 +1,298 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// The size of each chunk of a snapshot streamed to a follower, which keeps
+// messages well below gRPC's default 4MB limit.
+const snapshotChunkSize int = 1 << 20
+
+// The deadline for sending a single chunk of a snapshot.
+const snapshotChunkTimeout time.Duration = 10 * time.Second
+
+// The number of streams used to send a snapshot before giving up, since the
+// follower may ask the leader to restart the transfer at a different offset.
+const maxSnapshotStreams int = 3
+
+// SendSnapshot streams the snapshot to the specified address in chunks. If
+// the follower already received part of the snapshot, the transfer resumes
+// from the end of that part.
+func SendSnapshot(address string, term uint32, leaderId string, meta state.SnapshotMeta, data io.ReadSeeker) (*InstallSnapshotResponse, error) {
+	conn, err := Pool.Get(address)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &InstallSnapshotRequest{
+		Term:              term,
+		LeaderId:          leaderId,
+		LastIncludedIndex: meta.LastIncludedIndex,
+		LastIncludedTerm:  meta.LastIncludedTerm,
+		Nodes:             nodeHostsToProto(meta.Membership.Nodes),
+		NewNodes:          nodeHostsToProto(meta.Membership.NewNodes),
+		Learners:          nodeHostsToProto(meta.Membership.Learners),
+		Size:              uint64(meta.Size),
+		Checksum:          meta.Checksum,
+	}
+
+	var offset uint64
+	for i := 0; i < maxSnapshotStreams; i++ {
+		response, err := streamSnapshot(NewGoRaftClient(conn), header, data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if response.Installed || response.Term > term {
+			return response, nil
+		}
+		offset = response.NextOffset
+	}
+	return nil, errors.New("snapshot transfer did not complete")
+}
+
+// streamSnapshot sends the chunks of the snapshot starting at the offset in a
+// single stream, returning the follower's response.
+func streamSnapshot(client GoRaftClient, header *InstallSnapshotRequest, data io.ReadSeeker, offset uint64) (*InstallSnapshotResponse, error) {
+	if offset > header.Size {
+		offset = 0
+	}
+	_, err := data.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole transfer may take much longer than any one request, so the
+	// stream is only canceled if a single chunk takes too long to send.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchdog := time.AfterFunc(snapshotChunkTimeout, cancel)
+	defer watchdog.Stop()
+
+	stream, err := client.InstallSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, snapshotChunkSize)
+	for {
+		n, err := io.ReadFull(data, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		done := offset+uint64(n) >= header.Size
+		if n == 0 && !done {
+			return nil, errors.New("snapshot data is shorter than its size")
+		}
+
+		err = stream.Send(&InstallSnapshotRequest{
+			Term:              header.Term,
+			LeaderId:          header.LeaderId,
+			LastIncludedIndex: header.LastIncludedIndex,
+			LastIncludedTerm:  header.LastIncludedTerm,
+			Nodes:             header.Nodes,
+			NewNodes:          header.NewNodes,
+			Learners:          header.Learners,
+			Size:              header.Size,
+			Checksum:          header.Checksum,
+			Offset:            offset,
+			Data:              buffer[:n],
+			ChunkChecksum:     state.UpdateChecksum(0, buffer[:n]),
+			Done:              done,
+		})
+		if err == io.EOF {
+			// The follower ended the stream early, and its response says why.
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		watchdog.Reset(snapshotChunkTimeout)
+
+		offset += uint64(n)
+		if done {
+			break
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
+
+// snapshotReceiver writes the chunks of a snapshot received from the leader
+// to the snapshot store. The partially received snapshot is kept between
+// streams so that an interrupted transfer can be resumed.
+type snapshotReceiver struct {
+	sync.Mutex
+
+	// The first chunk of the snapshot being received, or nil if there is none.
+	header *InstallSnapshotRequest
+
+	sink     state.SnapshotSink
+	received uint64
+}
+
+// receiver receives the snapshots streamed to this node.
+var receiver = &snapshotReceiver{}
+
+// matches returns true if the chunk belongs to the snapshot being received.
+func (receiver *snapshotReceiver) matches(request *InstallSnapshotRequest) bool {
+	return receiver.header != nil &&
+		receiver.header.LastIncludedIndex == request.LastIncludedIndex &&
+		receiver.header.LastIncludedTerm == request.LastIncludedTerm &&
+		receiver.header.Size == request.Size &&
+		receiver.header.Checksum == request.Checksum
+}
+
+// reset discards the snapshot being received.
+func (receiver *snapshotReceiver) reset() {
+	if receiver.sink != nil {
+		receiver.sink.Cancel()
+	}
+	receiver.header = nil
+	receiver.sink = nil
+	receiver.received = 0
+}
+
+// progress returns a response with the offset of the next chunk expected.
+func (receiver *snapshotReceiver) progress() *InstallSnapshotResponse {
+	receiver.Lock()
+	defer receiver.Unlock()
+
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	return &InstallSnapshotResponse{Term: nodeState.CurrentTerm(), NextOffset: receiver.received}
+}
+
+// receive writes the chunk to the snapshot being received, and installs the
+// snapshot once the last chunk has been received and the snapshot has been
+// verified. It returns a response if the stream should be ended, or nil if
+// more chunks are expected.
+func (receiver *snapshotReceiver) receive(request *InstallSnapshotRequest) (*InstallSnapshotResponse, error) {
+	receiver.Lock()
+	defer receiver.Unlock()
+
+	nodeState := state.GetNodeState()
+	nodeState.Lock()
+	response := &InstallSnapshotResponse{Term: nodeState.CurrentTerm()}
+
+	// Don't accept a snapshot from a stale leader.
+	if request.Term < response.Term {
+		nodeState.Unlock()
+		return response, nil
+	} else if nodeState.StepDown(request.Term) {
+		response.Term = request.Term
+	}
+
+	// Indicate that a message has been received from the leader so we don't
+	// time out while the snapshot is being transferred.
+	nodeState.BecomeFollower(request.LeaderId)
+	global.ResetTimeout()
+	snapshotStore := nodeState.SnapshotStore
+	nodeState.Unlock()
+
+	if !receiver.matches(request) {
+		if request.Offset != 0 {
+			return response, nil
+		}
+
+		receiver.reset()
+		sink, err := snapshotStore.Create(request.LastIncludedIndex, request.LastIncludedTerm, membershipFromProto(request))
+		if err != nil {
+			return nil, err
+		}
+		receiver.header = request
+		receiver.sink = sink
+	}
+
+	// Ask the leader to restart at the end of the data that was received, or
+	// to resend the chunk if it was corrupted.
+	response.NextOffset = receiver.received
+	if request.Offset != receiver.received {
+		return response, nil
+	} else if state.UpdateChecksum(0, request.Data) != request.ChunkChecksum {
+		global.Log.Warningf("Snapshot chunk at offset %d has an invalid checksum", request.Offset)
+		return response, nil
+	}
+
+	_, err := receiver.sink.Write(request.Data)
+	if err != nil {
+		receiver.reset()
+		return nil, err
+	}
+	receiver.received += uint64(len(request.Data))
+	if !request.Done {
+		return nil, nil
+	}
+
+	// The snapshot is only installed once all of it has been received intact.
+	sink := receiver.sink
+	receiver.sink = nil
+	receiver.reset()
+
+	meta := sink.Meta()
+	if uint64(meta.Size) != request.Size || meta.Checksum != request.Checksum {
+		sink.Cancel()
+		return nil, status.Error(codes.DataLoss, "snapshot does not match its size and checksum")
+	}
+	err = sink.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeState.Lock()
+	defer nodeState.Unlock()
+
+	// Leadership may have changed while the last chunk was being written.
+	if nodeState.CurrentTerm() != request.Term {
+		response.Term = nodeState.CurrentTerm()
+		return response, nil
+	}
+
+	err = nodeState.InstallSnapshot(meta)
+	if err != nil {
+		return nil, err
+	}
+	response.NextOffset = uint64(meta.Size)
+	response.Installed = true
+	return response, nil
+}
+
+// nodeHostsToProto converts the nodes to their protobuf messages.
+func nodeHostsToProto(nodes map[string]global.NodeHost) map[string]*NodeHost {
+	protoNodes := make(map[string]*NodeHost, len(nodes))
+	for nodeId, host := range nodes {
+		protoNodes[nodeId] = &NodeHost{Url: host.Url, ApiPort: host.ApiPort, RpcPort: host.RpcPort}
+	}
+	return protoNodes
+}
+
+// membershipFromProto returns the membership included in the snapshot chunk.
+func membershipFromProto(request *InstallSnapshotRequest) state.Membership {
+	membership := state.Membership{Nodes: nodeHostsFromProto(request.Nodes)}
+	if len(request.NewNodes) > 0 {
+		membership.NewNodes = nodeHostsFromProto(request.NewNodes)
+	}
+	if len(request.Learners) > 0 {
+		membership.Learners = nodeHostsFromProto(request.Learners)
+	}
+	return membership
+}
+
+// nodeHostsFromProto converts the protobuf messages to nodes.
+func nodeHostsFromProto(protoNodes map[string]*NodeHost) map[string]global.NodeHost {
+	nodes := make(map[string]global.NodeHost, len(protoNodes))
+	for nodeId, host := range protoNodes {
+		nodes[nodeId] = global.NodeHost{Url: host.Url, ApiPort: host.ApiPort, RpcPort: host.RpcPort}
+	}
+	return nodes
+}
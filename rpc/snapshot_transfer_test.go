@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/thomasylee/GoRaft/global"
+	"github.com/thomasylee/GoRaft/state"
+)
+
+// createLeaderSnapshot creates a snapshot in a separate store that is large
+// enough to be sent in several chunks.
+func createLeaderSnapshot(t *testing.T) (*state.MemorySnapshotStore, state.SnapshotMeta) {
+	data := make(map[string]string)
+	for i := 0; i < 50000; i++ {
+		data["key"+strconv.Itoa(i)] = "value-padded-to-make-the-snapshot-larger-" + strconv.Itoa(i)
+	}
+
+	store := state.NewMemorySnapshotStore()
+	membership := state.Membership{
+		Nodes:    map[string]global.NodeHost{"1": {Url: "10.0.0.1"}, "2": {Url: "10.0.0.2"}},
+		NewNodes: map[string]global.NodeHost{"2": {Url: "10.0.0.2"}, "3": {Url: "10.0.0.3"}},
+	}
+	sink, err := store.Create(3, 2, membership)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = state.WriteSnapshotData(sink, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := sink.Meta()
+	if meta.Size <= int64(2*snapshotChunkSize) {
+		t.Fatal("Snapshot is not larger than two chunks:", meta.Size)
+	}
+	return store, meta
+}
+
+func Test_SendSnapshot_WithLargeSnapshot_InstallsSnapshotOnFollower(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(1)
+	state.Node.SetVotedFor("1")
+	state.Node.SetLogEntry(1, state.LogEntry{Term: 1, Key: "a", Value: "A"})
+
+	store, meta := createLeaderSnapshot(t)
+	_, reader, _ := store.Open(meta.Id)
+	defer reader.Close()
+
+	response, err := SendSnapshot("127.0.0.1:"+port, 2, "123", meta, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Installed {
+		t.Error("Installed was false")
+	}
+
+	if response.Term != 2 || state.Node.CurrentTerm() != 2 {
+		t.Error("Term was not 2:", response.Term, state.Node.CurrentTerm())
+	}
+	if state.Node.VotedFor() != "" {
+		t.Error("Vote from the older term was kept:", state.Node.VotedFor())
+	}
+
+	if state.Node.LogOffset() != 3 {
+		t.Error("LogOffset was not 3:", state.Node.LogOffset())
+	}
+
+	if state.Node.CommitIndex != 3 {
+		t.Error("CommitIndex was not 3:", state.Node.CommitIndex)
+	}
+
+	if !reflect.DeepEqual(state.Node.Membership(), meta.Membership) {
+		t.Error("Membership was not the snapshot's membership:", state.Node.Membership())
+	}
+
+	installed, err := state.Node.LatestSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	} else if installed == nil || installed.Size != meta.Size || installed.Checksum != meta.Checksum {
+		t.Errorf("Snapshot was not saved with size %d and checksum %d: %v", meta.Size, meta.Checksum, installed)
+	}
+}
+
+func Test_SendSnapshot_WhenTransferWasInterrupted_ResumesTransfer(t *testing.T) {
+	resetTestEnvironment()
+
+	state.Node.SetCurrentTerm(2)
+
+	store, meta := createLeaderSnapshot(t)
+	_, reader, _ := store.Open(meta.Id)
+	defer reader.Close()
+
+	chunk := make([]byte, snapshotChunkSize)
+	reader.Read(chunk)
+
+	conn, err := Pool.Get("127.0.0.1:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send only the first chunk, and then one with a bad checksum.
+	stream, err := NewGoRaftClient(conn).InstallSnapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &InstallSnapshotRequest{
+		Term:              2,
+		LeaderId:          "123",
+		LastIncludedIndex: meta.LastIncludedIndex,
+		LastIncludedTerm:  meta.LastIncludedTerm,
+		Size:              uint64(meta.Size),
+		Checksum:          meta.Checksum,
+		Data:              chunk,
+		ChunkChecksum:     state.UpdateChecksum(0, chunk),
+	}
+	stream.Send(request)
+	request.Offset = uint64(len(chunk))
+	request.ChunkChecksum++
+	stream.Send(request)
+
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Installed {
+		t.Error("Installed was true")
+	}
+
+	if response.NextOffset != uint64(len(chunk)) {
+		t.Errorf("NextOffset was not %d: %d", len(chunk), response.NextOffset)
+	}
+
+	response, err = SendSnapshot("127.0.0.1:"+port, 2, "123", meta, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Installed {
+		t.Error("Installed was false")
+	}
+
+	installed, err := state.Node.LatestSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	} else if installed == nil || installed.Checksum != meta.Checksum {
+		t.Errorf("Snapshot was not saved with checksum %d: %v", meta.Checksum, installed)
+	}
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/json"
+	"io"
 	"strconv"
 	"time"
 
@@ -52,6 +53,22 @@ func (boltSM BoltDataStore) Put(key string, value string) error {
 	})
 }
 
+// PutAll writes the key-value pairs to the Bolt database in a single
+// transaction.
+func (boltSM BoltDataStore) PutAll(values map[string]string) error {
+	return boltSM.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucket))
+
+		for key, value := range values {
+			err := bucket.Put([]byte(key), []byte(value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Get returns the value of the specified key stored in the Bolt database.
 func (boltSM BoltDataStore) Get(key string) (string, error) {
 	var value string
@@ -70,6 +87,48 @@ func (boltSM BoltDataStore) Get(key string) (string, error) {
 	return value, err
 }
 
+// ForEach calls f with every key-value pair stored in the Bolt database,
+// within a single read transaction.
+func (boltSM BoltDataStore) ForEach(f func(key string, value string) error) error {
+	return boltSM.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucket))
+		return bucket.ForEach(func(key []byte, value []byte) error {
+			return f(string(key), string(value))
+		})
+	})
+}
+
+// ReplaceAll replaces the contents of the Bolt database with the key-value
+// pairs returned by next in a single transaction, which is rolled back if
+// next fails.
+func (boltSM BoltDataStore) ReplaceAll(next func() (string, string, error)) error {
+	return boltSM.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(bucket))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		bucket, err := tx.CreateBucket([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		for {
+			key, value, err := next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			err = bucket.Put([]byte(key), []byte(value))
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
 // RetrieveLogEntries returns log entries within the specified key range.
 //
 // TODO: Use a more efficient method than querying each index one at a time.
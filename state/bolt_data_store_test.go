@@ -1,10 +1,25 @@
 package state
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"testing"
 )
 
+// allValues returns every key-value pair in the data store.
+func allValues(t *testing.T, store DataStore) map[string]string {
+	values := make(map[string]string)
+	err := store.ForEach(func(key string, value string) error {
+		values[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
 func Test_BoltPutAndGet_WithCreatedBucket_PutsAndGetsCorrectly(t *testing.T) {
 	dataStoreFile := "test_temp_db"
 
@@ -85,3 +100,79 @@ func Test_RetrieveBoltLogEntries_WhenWholeRangeExists_ReturnsLogEntriesInRange(t
 
 	os.Remove(dataStoreFile)
 }
+
+func Test_BoltReplaceAll_WithExistingValues_ReplacesAllValues(t *testing.T) {
+	dataStoreFile := "test_temp_db"
+
+	bolt, err := NewBoltDataStore(dataStoreFile)
+	if err != nil {
+		t.Fatal("Creating BoltDataStore failed:", err)
+	}
+	defer os.Remove(dataStoreFile)
+
+	bolt.Put("a", "old")
+	bolt.Put("b", "B")
+
+	var buffer bytes.Buffer
+	WriteSnapshotData(&buffer, map[string]string{"a": "A", "c": "C"})
+	err = bolt.ReplaceAll(NewSnapshotDataReader(&buffer).Next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := allValues(t, bolt)
+	if len(values) != 2 || values["a"] != "A" || values["c"] != "C" {
+		t.Error("Values were not replaced:", values)
+	}
+}
+
+func Test_BoltReplaceAll_WhenNextFails_KeepsExistingValues(t *testing.T) {
+	dataStoreFile := "test_temp_db"
+
+	bolt, err := NewBoltDataStore(dataStoreFile)
+	if err != nil {
+		t.Fatal("Creating BoltDataStore failed:", err)
+	}
+	defer os.Remove(dataStoreFile)
+
+	bolt.Put("a", "old")
+
+	returned := false
+	err = bolt.ReplaceAll(func() (string, string, error) {
+		if returned {
+			return "", "", errors.New("read failed")
+		}
+		returned = true
+		return "b", "B", nil
+	})
+	if err == nil {
+		t.Fatal("ReplaceAll did not fail")
+	}
+
+	values := allValues(t, bolt)
+	if len(values) != 1 || values["a"] != "old" {
+		t.Error("Values were replaced:", values)
+	}
+}
+
+func Test_BoltPutAll_WithSeveralValues_PutsAllValues(t *testing.T) {
+	dataStoreFile := "test_temp_db"
+
+	bolt, err := NewBoltDataStore(dataStoreFile)
+	if err != nil {
+		t.Fatal("Creating BoltDataStore failed:", err)
+	}
+	defer os.Remove(dataStoreFile)
+
+	bolt.Put("a", "old")
+
+	err = bolt.PutAll(map[string]string{"a": "A", "b": "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := allValues(t, bolt)
+	if len(values) != 2 || values["a"] != "A" || values["b"] != "B" {
+		t.Error("Values were not put:", values)
+	}
+}
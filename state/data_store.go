@@ -3,6 +3,18 @@ package state
 // DataStore represents any kind of key-value database.
 type DataStore interface {
 	Put(string, string) error
+
+	// PutAll writes the key-value pairs in a single transaction.
+	PutAll(map[string]string) error
+
 	Get(string) (string, error)
 	RetrieveLogEntries(int, int) ([]LogEntry, error)
+
+	// ForEach calls f with every key-value pair in the data store as of a
+	// single point in time, stopping at the first error that f returns.
+	ForEach(f func(key string, value string) error) error
+
+	// ReplaceAll replaces the contents of the data store with the key-value
+	// pairs returned by next, which returns io.EOF after the last pair.
+	ReplaceAll(next func() (string, string, error)) error
 }
@@ -0,0 +1,305 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+// Each snapshot is stored in its own directory containing these files.
+const (
+	snapshotMetaFile string = "meta.json"
+	snapshotDataFile string = "state.dat"
+
+	// Snapshot data used to be a single JSON object stored in this file.
+	// Snapshots in that format are converted when the store is opened.
+	legacySnapshotDataFile string = "state.json"
+
+	// Snapshots are written to a directory with this suffix and renamed once
+	// they are complete, so that partial snapshots are never listed.
+	snapshotTempSuffix string = ".tmp"
+)
+
+// FileSnapshotStore stores snapshots as files in a directory.
+type FileSnapshotStore struct {
+	dir string
+}
+
+// NewFileSnapshotStore returns a new instance of the FileSnapshotStore type,
+// creating the directory if it does not exist, removing any snapshots that
+// were left incomplete, and converting snapshots in the legacy format.
+func NewFileSnapshotStore(dir string) (*FileSnapshotStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), snapshotTempSuffix) {
+			global.Log.Info("Removing incomplete snapshot:", entry.Name())
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	store := &FileSnapshotStore{dir: dir}
+	err = store.convertLegacySnapshots()
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// convertLegacySnapshots replaces each snapshot whose data is still a JSON
+// object in the legacy data file with a new snapshot with the same metadata
+// and the data in the current format.
+func (store *FileSnapshotStore) convertLegacySnapshots() error {
+	snapshots, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range snapshots {
+		legacyFile := filepath.Join(store.dir, meta.Id, legacySnapshotDataFile)
+		jsonValue, err := ioutil.ReadFile(legacyFile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		values := make(map[string]string)
+		err = json.Unmarshal(jsonValue, &values)
+		if err != nil {
+			return err
+		}
+
+		sink, err := store.Create(meta.LastIncludedIndex, meta.LastIncludedTerm, meta.Membership)
+		if err != nil {
+			return err
+		}
+		err = WriteSnapshotData(sink, values)
+		if err != nil {
+			sink.Cancel()
+			return err
+		}
+		err = sink.Close()
+		if err != nil {
+			return err
+		}
+
+		global.Log.Info("Converted snapshot to the current format:", meta.Id)
+		err = os.RemoveAll(filepath.Join(store.dir, meta.Id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create starts writing a new snapshot to a temporary directory.
+func (store *FileSnapshotStore) Create(lastIncludedIndex uint32, lastIncludedTerm uint32, membership Membership) (SnapshotSink, error) {
+	meta := SnapshotMeta{
+		Id:                newSnapshotId(lastIncludedIndex, lastIncludedTerm),
+		LastIncludedIndex: lastIncludedIndex,
+		LastIncludedTerm:  lastIncludedTerm,
+		Membership:        membership,
+	}
+
+	tempDir := filepath.Join(store.dir, meta.Id+snapshotTempSuffix)
+	err := os.MkdirAll(tempDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(filepath.Join(tempDir, snapshotDataFile))
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+
+	return &fileSnapshotSink{
+		store:   store,
+		meta:    meta,
+		tempDir: tempDir,
+		file:    file,
+		writer:  bufio.NewWriter(file),
+	}, nil
+}
+
+// List returns the metadata of the snapshots in the directory, newest first.
+func (store *FileSnapshotStore) List() ([]SnapshotMeta, error) {
+	entries, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []SnapshotMeta{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), snapshotTempSuffix) {
+			continue
+		}
+
+		meta, err := store.readMeta(entry.Name())
+		if err != nil {
+			global.Log.Warning("Skipping unreadable snapshot:", entry.Name(), err.Error())
+			continue
+		}
+		snapshots = append(snapshots, meta)
+	}
+
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+// Open returns the metadata of the snapshot and a reader for its data file.
+func (store *FileSnapshotStore) Open(id string) (SnapshotMeta, SnapshotReader, error) {
+	meta, err := store.readMeta(id)
+	if err != nil {
+		return SnapshotMeta{}, nil, err
+	}
+
+	file, err := os.Open(filepath.Join(store.dir, id, snapshotDataFile))
+	if err != nil {
+		return SnapshotMeta{}, nil, err
+	}
+	return meta, file, nil
+}
+
+// Retain removes the directories of all but the newest count snapshots.
+func (store *FileSnapshotStore) Retain(count int) error {
+	snapshots, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	for i := count; i < len(snapshots); i++ {
+		err = os.RemoveAll(filepath.Join(store.dir, snapshots[i].Id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMeta reads the metadata file of the snapshot with the given id.
+func (store *FileSnapshotStore) readMeta(id string) (SnapshotMeta, error) {
+	var meta SnapshotMeta
+
+	jsonValue, err := ioutil.ReadFile(filepath.Join(store.dir, id, snapshotMetaFile))
+	if err != nil {
+		return meta, err
+	}
+
+	err = json.Unmarshal(jsonValue, &meta)
+	return meta, err
+}
+
+// fileSnapshotSink writes a snapshot to a temporary directory, which is
+// renamed into place when the sink is closed.
+type fileSnapshotSink struct {
+	store   *FileSnapshotStore
+	meta    SnapshotMeta
+	tempDir string
+	file    *os.File
+	writer  *bufio.Writer
+}
+
+// Write writes snapshot data to the data file.
+func (sink *fileSnapshotSink) Write(data []byte) (int, error) {
+	n, err := sink.writer.Write(data)
+	sink.meta.Size += int64(n)
+	sink.meta.Checksum = UpdateChecksum(sink.meta.Checksum, data[:n])
+	return n, err
+}
+
+// Meta returns the metadata of the snapshot being written.
+func (sink *fileSnapshotSink) Meta() SnapshotMeta {
+	return sink.meta
+}
+
+// Close syncs the data and metadata files to disk and then atomically renames
+// the temporary directory so that the snapshot is listed.
+func (sink *fileSnapshotSink) Close() error {
+	err := sink.finish()
+	if err != nil {
+		os.RemoveAll(sink.tempDir)
+		return err
+	}
+	return nil
+}
+
+// finish does the work of Close, leaving any cleanup to the caller.
+func (sink *fileSnapshotSink) finish() error {
+	err := sink.writer.Flush()
+	if err != nil {
+		sink.file.Close()
+		return err
+	}
+	err = sink.file.Sync()
+	if err != nil {
+		sink.file.Close()
+		return err
+	}
+	err = sink.file.Close()
+	if err != nil {
+		return err
+	}
+
+	jsonValue, err := json.Marshal(sink.meta)
+	if err != nil {
+		return err
+	}
+	err = writeFileSync(filepath.Join(sink.tempDir, snapshotMetaFile), jsonValue)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(sink.tempDir, filepath.Join(sink.store.dir, sink.meta.Id))
+	if err != nil {
+		return err
+	}
+	return syncDir(sink.store.dir)
+}
+
+// Cancel closes and removes the temporary directory.
+func (sink *fileSnapshotSink) Cancel() error {
+	sink.file.Close()
+	return os.RemoveAll(sink.tempDir)
+}
+
+// writeFileSync writes the data to the named file and syncs it to disk.
+func writeFileSync(name string, data []byte) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// syncDir syncs the directory to disk so that renames within it are durable.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
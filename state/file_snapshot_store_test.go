@@ -0,0 +1,203 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func createSnapshot(t *testing.T, store SnapshotStore, index uint32, data map[string]string) SnapshotMeta {
+	membership := Membership{Nodes: map[string]global.NodeHost{"host1": {Url: "localhost", ApiPort: 8000, RpcPort: 9000}}}
+	sink, err := store.Create(index, 1, membership)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteSnapshotData(sink, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink.Meta()
+}
+
+func Test_FileSnapshotStore_WithCreatedSnapshot_ListsAndOpensSnapshot(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := createSnapshot(t, store, 10, map[string]string{"a": "A"})
+
+	// Reopen the store to make sure the snapshot was written to disk.
+	store, err = NewFileSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatal("Number of snapshots was not 1:", len(snapshots))
+	}
+	if snapshots[0].Id != created.Id || snapshots[0].LastIncludedIndex != 10 || snapshots[0].Size != created.Size {
+		t.Errorf("Listed snapshot did not match %v: %v", created, snapshots[0])
+	}
+	if snapshots[0].Membership.Nodes["host1"].RpcPort != 9000 {
+		t.Error("Snapshot membership was not saved:", snapshots[0].Membership)
+	}
+
+	_, reader, err := store.Open(created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := readSnapshotData(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["a"] != "A" {
+		t.Error("Snapshot data did not match:", data)
+	}
+}
+
+func Test_NewFileSnapshotStore_WithLegacySnapshot_ConvertsSnapshot(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A snapshot written before its data was stored as records.
+	legacy := SnapshotMeta{Id: "1-10-1", LastIncludedIndex: 10, LastIncludedTerm: 1}
+	os.Mkdir(filepath.Join(dir, legacy.Id), 0700)
+	metaJson, _ := json.Marshal(legacy)
+	ioutil.WriteFile(filepath.Join(dir, legacy.Id, snapshotMetaFile), metaJson, 0600)
+	ioutil.WriteFile(filepath.Join(dir, legacy.Id, legacySnapshotDataFile), []byte(`{"a":"A"}`), 0600)
+
+	store, err := NewFileSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Id == legacy.Id || snapshots[0].LastIncludedIndex != 10 {
+		t.Fatal("Legacy snapshot was not replaced:", snapshots)
+	}
+
+	_, reader, err := store.Open(snapshots[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := readSnapshotData(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data["a"] != "A" {
+		t.Error("Snapshot data was not converted:", data)
+	}
+}
+
+func Test_FileSnapshotStore_WhenSnapshotIsCanceled_DoesNotListSnapshot(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, err := store.Create(10, 1, Membership{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled.Write([]byte("{"))
+	canceled.Cancel()
+
+	// An unfinished snapshot is left behind as if the node crashed.
+	_, err = store.Create(20, 1, Membership{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 0 {
+		t.Error("Number of snapshots was not 0:", len(snapshots))
+	}
+
+	// Reopening the store removes the unfinished snapshot.
+	_, err = NewFileSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Error("Unfinished snapshot was not removed:", filepath.Join(dir, entries[0].Name()))
+	}
+}
+
+func Test_FileSnapshotStore_Retain_RemovesOlderSnapshots(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createSnapshot(t, store, 20, map[string]string{})
+	createSnapshot(t, store, 10, map[string]string{})
+	createSnapshot(t, store, 30, map[string]string{})
+
+	err = store.Retain(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].LastIncludedIndex != 30 || snapshots[1].LastIncludedIndex != 20 {
+		t.Error("Snapshots 30 and 20 were not retained:", snapshots)
+	}
+}
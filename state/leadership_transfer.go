@@ -0,0 +1,89 @@
+package state
+
+import (
+	"errors"
+)
+
+// Errors returned by StartLeadershipTransfer and ChangeMembership.
+var (
+	ErrTransferInProgress = errors.New("a leadership transfer is in progress")
+	ErrNoTransferTarget   = errors.New("there is no other voter to transfer leadership to")
+	ErrNotVoter           = errors.New("node is not a voter")
+)
+
+// StartLeadershipTransfer starts transferring leadership to the node, or to
+// the voter with the most entries if nodeId is empty, and returns the target.
+// The leader refuses new proposals until the transfer ends, and once the
+// target's log has caught up, the target is told to start an election.
+func (state *NodeState) StartLeadershipTransfer(leaderId string, nodeId string) (string, error) {
+	if state.role != Leader {
+		return "", ErrNotLeader
+	} else if state.transferTarget != "" {
+		return "", ErrTransferInProgress
+	}
+
+	if nodeId == "" {
+		for _, voterId := range state.membership.VoterIds() {
+			if voterId != leaderId && (nodeId == "" || state.MatchIndex[voterId] > state.MatchIndex[nodeId]) {
+				nodeId = voterId
+			}
+		}
+		if nodeId == "" {
+			return "", ErrNoTransferTarget
+		}
+	} else if nodeId == leaderId || !state.membership.IsVoter(nodeId) {
+		return "", ErrNotVoter
+	}
+
+	state.transferTarget = nodeId
+	state.timeoutNowSent = false
+	return nodeId, nil
+}
+
+// EndLeadershipTransfer ends the transfer in progress, if any, so that the
+// leader accepts proposals again. A lease revoked by the transfer is renewed
+// by the next responses to requests sent after it ended.
+func (state *NodeState) EndLeadershipTransfer() {
+	if state.role == Leader && state.leaseRevoked {
+		state.resetLease()
+	}
+	state.transferTarget = ""
+	state.timeoutNowSent = false
+}
+
+// TransferringLeadership returns true if the node is the leader and is
+// transferring leadership to another node.
+func (state *NodeState) TransferringLeadership() bool {
+	return state.role == Leader && state.transferTarget != ""
+}
+
+// TimeoutNowReady returns true if the node is the target of the leadership
+// transfer in progress and has every entry in the leader's log, so that it
+// should be told to start an election. It only returns true once per
+// transfer.
+func (state *NodeState) TimeoutNowReady(nodeId string) bool {
+	if !state.TransferringLeadership() || state.transferTarget != nodeId || state.timeoutNowSent {
+		return false
+	} else if state.MatchIndex[nodeId] < state.LogLength() {
+		return false
+	}
+
+	state.timeoutNowSent = true
+	state.leaseRevoked = true
+	return true
+}
+
+// BecomeTransferCandidate makes the node a candidate like BecomeCandidate,
+// because the leader is transferring leadership to it.
+func (state *NodeState) BecomeTransferCandidate(nodeId string) (term uint32, ok bool) {
+	term, ok = state.BecomeCandidate(nodeId)
+	state.transferElection = ok
+	return term, ok
+}
+
+// TransferElection returns true if the node is a candidate because the leader
+// transferred leadership to it, in which case the other nodes vote for it even
+// if they have recently heard from the leader.
+func (state *NodeState) TransferElection() bool {
+	return state.role == Candidate && state.transferElection
+}
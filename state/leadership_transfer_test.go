@@ -0,0 +1,76 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func Test_StartLeadershipTransfer_WithoutTarget_PicksMostUpToDateVoter(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+	node.AppendLeaderEntry("a", "A")
+	node.AppendLeaderEntry("b", "B")
+	node.MatchIndex["2"] = 1
+	node.MatchIndex["3"] = 2
+
+	if _, err := node.StartLeadershipTransfer("1", "1"); err != ErrNotVoter {
+		t.Error("Transfer to the leader was not rejected:", err)
+	}
+
+	target, err := node.StartLeadershipTransfer("1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "3" {
+		t.Error("Target was not 3:", target)
+	}
+	if !node.TransferringLeadership() {
+		t.Error("Leader was not transferring leadership")
+	}
+
+	if _, err = node.StartLeadershipTransfer("1", "2"); err != ErrTransferInProgress {
+		t.Error("Second transfer was not rejected:", err)
+	}
+	if _, _, err = node.ChangeMembership(map[string]global.NodeHost{"1": {}}, nil); err != ErrTransferInProgress {
+		t.Error("Membership change was not rejected:", err)
+	}
+
+	if !node.TimeoutNowReady("3") {
+		t.Error("TimeoutNow was not ready for the caught up target")
+	}
+	if node.TimeoutNowReady("3") {
+		t.Error("TimeoutNow was ready more than once")
+	}
+
+	node.EndLeadershipTransfer()
+	if node.TransferringLeadership() {
+		t.Error("Leader was still transferring leadership")
+	}
+}
+
+func Test_TimeoutNowReady_WhenTargetIsBehind_ReturnsFalse(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+	node.AppendLeaderEntry("a", "A")
+
+	node.StartLeadershipTransfer("1", "2")
+	if node.TimeoutNowReady("2") {
+		t.Error("TimeoutNow was ready before the target had every entry")
+	}
+
+	node.MatchIndex["2"] = node.LogLength()
+	if !node.TimeoutNowReady("2") {
+		t.Error("TimeoutNow was not ready once the target had every entry")
+	}
+}
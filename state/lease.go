@@ -0,0 +1,50 @@
+package state
+
+import "time"
+
+// With lease reads, the leader serves reads without contacting the other
+// nodes while it holds a lease. Followers that have heard from the leader
+// within the minimum election timeout refuse to vote for other candidates, so
+// once a quorum has responded to requests sent at some time, no other leader
+// can be elected until the minimum election timeout after that time. The lease
+// is measured from when the requests were sent, so that delays in the network
+// only shorten it.
+//
+// The lease is revoked when the target of a leadership transfer is told to
+// start an election, since its election ignores the followers' leases. If the
+// transfer is aborted, only responses to requests sent after the abort renew
+// the lease, since they come from followers that hadn't moved on to the
+// target's term.
+
+// AckLease records that the node responded to a request sent at the given
+// time in the leader's term.
+func (state *NodeState) AckLease(nodeId string, sent time.Time) {
+	if state.role != Leader || sent.Before(state.leaseStart) {
+		return
+	}
+	if sent.After(state.leaseAcks[nodeId]) {
+		state.leaseAcks[nodeId] = sent
+	}
+}
+
+// LeaseValid returns true if the node is the leader and a quorum has
+// responded to requests sent within the given duration. The lease isn't valid
+// after the target of a leadership transfer has been told to start an
+// election, until the transfer is aborted.
+func (state *NodeState) LeaseValid(leaderId string, duration time.Duration) bool {
+	if state.role != Leader || state.leaseRevoked || duration <= 0 {
+		return false
+	}
+	return state.membership.HasQuorum(func(nodeId string) bool {
+		return nodeId == leaderId || time.Since(state.leaseAcks[nodeId]) < duration
+	})
+}
+
+// resetLease discards the lease, so that only responses to requests sent from
+// now on count toward it. It is reset when the node becomes leader and when a
+// leadership transfer that revoked the lease is aborted.
+func (state *NodeState) resetLease() {
+	state.leaseAcks = make(map[string]time.Time)
+	state.leaseRevoked = false
+	state.leaseStart = time.Now()
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func Test_LeaseValid_WhenQuorumRespondedToRecentRequests_ReturnsTrue(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	if node.LeaseValid("1", time.Second) {
+		t.Error("Lease was valid before any node responded")
+	}
+
+	// A response to a request sent long ago doesn't extend the lease.
+	node.AckLease("2", time.Now().Add(-2*time.Second))
+	if node.LeaseValid("1", time.Second) {
+		t.Error("Lease was valid from a request sent before the duration")
+	}
+
+	// The leader and node 2 form a quorum.
+	node.AckLease("2", time.Now())
+	if !node.LeaseValid("1", time.Second) {
+		t.Error("Lease was not valid after a quorum responded")
+	}
+	if node.LeaseValid("1", 0) {
+		t.Error("Lease was valid with a zero duration")
+	}
+
+	// An older response doesn't replace a newer one.
+	node.AckLease("2", time.Now().Add(-2*time.Second))
+	if !node.LeaseValid("1", time.Second) {
+		t.Error("Older response shortened the lease")
+	}
+
+	// The lease of an earlier term doesn't carry over.
+	node.StepDown(term + 1)
+	if node.LeaseValid("1", time.Second) {
+		t.Error("Lease was valid after stepping down")
+	}
+	term, _ = node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+	if node.LeaseValid("1", time.Second) {
+		t.Error("Lease of an earlier term was valid")
+	}
+}
+
+func Test_LeaseValid_WhenTransferTargetWasToldToStartElection_ReturnsFalse(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+	node.AckLease("2", time.Now())
+
+	if _, err := node.StartLeadershipTransfer("1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	node.MatchIndex["2"] = node.LogLength()
+	if !node.TimeoutNowReady("2") {
+		t.Fatal("Transfer target was not ready")
+	}
+
+	node.AckLease("2", time.Now())
+	if node.LeaseValid("1", time.Second) {
+		t.Error("Lease was valid after the transfer target was told to start an election")
+	}
+}
+
+func Test_LeaseValid_WhenTransferIsAborted_RenewsLeaseWithRequestsSentAfterAbort(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	node.StartLeadershipTransfer("1", "2")
+	node.MatchIndex["2"] = node.LogLength()
+	node.TimeoutNowReady("2")
+	sentDuringTransfer := time.Now()
+	node.AckLease("2", sentDuringTransfer)
+
+	// A response to a request sent before the abort may come from a
+	// follower that goes on to vote for the target.
+	time.Sleep(time.Millisecond)
+	node.EndLeadershipTransfer()
+	node.AckLease("3", sentDuringTransfer)
+	if node.LeaseValid("1", time.Second) {
+		t.Error("Lease was renewed by a request sent before the transfer was aborted")
+	}
+
+	node.AckLease("3", time.Now())
+	if !node.LeaseValid("1", time.Second) {
+		t.Error("Lease was not renewed by a request sent after the transfer was aborted")
+	}
+}
+
+func Test_BecomeTransferCandidate_WhenFollower_StartsTransferElection(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}})
+
+	term, ok := node.BecomeTransferCandidate("1")
+	if !ok || term != 1 || !node.TransferElection() {
+		t.Error("Node did not start a transfer election:", term, ok, node.TransferElection())
+	}
+
+	// An election started after the first one times out is a normal one.
+	node.BecomeCandidate("1")
+	if node.TransferElection() {
+		t.Error("Election after a timeout was a transfer election")
+	}
+}
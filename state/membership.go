@@ -0,0 +1,335 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+// MembershipKey is the key of log entries that change the cluster membership,
+// whose values are JSON-encoded Memberships. Like the no-op entries that have
+// an empty key, they are not applied to the storage data store.
+const MembershipKey string = reservedKeyPrefix + "Membership"
+
+// Keys that start with this prefix are reserved for internal log entries.
+const reservedKeyPrefix string = "\x00"
+
+// A learner can only be promoted to a voter once its log is within this many
+// entries of the leader's log, so that it doesn't hold up commitment while it
+// catches up.
+const MaxPromotionLag uint32 = 100
+
+// Errors returned by ChangeMembership.
+var (
+	ErrNotLeader               = errors.New("node is not the leader")
+	ErrMembershipChangePending = errors.New("a membership change is already in progress")
+	ErrNoNodes                 = errors.New("membership must contain at least one node")
+)
+
+// IsReservedKey returns true if the key is reserved for internal log entries,
+// so that clients can't write it.
+func IsReservedKey(key string) bool {
+	return strings.HasPrefix(key, reservedKeyPrefix)
+}
+
+// Membership is the set of nodes in the cluster. While the cluster moves to a
+// new set of nodes using joint consensus, both the old and new sets are
+// included, and elections and commitment require a majority of each set.
+type Membership struct {
+	Nodes    map[string]global.NodeHost
+	NewNodes map[string]global.NodeHost `json:",omitempty"`
+
+	// Learners receive the log from the leader, but they don't vote and don't
+	// count toward commitment.
+	Learners map[string]global.NodeHost `json:",omitempty"`
+}
+
+// IsJoint returns true if the cluster is moving to a new set of nodes.
+func (membership Membership) IsJoint() bool {
+	return membership.NewNodes != nil
+}
+
+// NodeIds returns the ids of all the nodes that the leader replicates to,
+// including learners, in sorted order.
+func (membership Membership) NodeIds() []string {
+	return sortedIds(membership.Nodes, membership.NewNodes, membership.Learners)
+}
+
+// VoterIds returns the ids of the nodes in either set, in sorted order.
+func (membership Membership) VoterIds() []string {
+	return sortedIds(membership.Nodes, membership.NewNodes)
+}
+
+// sortedIds returns the ids of the nodes in any of the sets, in sorted order.
+func sortedIds(sets ...map[string]global.NodeHost) []string {
+	unique := make(map[string]bool)
+	for _, nodes := range sets {
+		for nodeId := range nodes {
+			unique[nodeId] = true
+		}
+	}
+
+	nodeIds := make([]string, 0, len(unique))
+	for nodeId := range unique {
+		nodeIds = append(nodeIds, nodeId)
+	}
+	sort.Strings(nodeIds)
+	return nodeIds
+}
+
+// Contains returns true if the node is a voter or a learner.
+func (membership Membership) Contains(nodeId string) bool {
+	_, ok := membership.Host(nodeId)
+	return ok
+}
+
+// IsVoter returns true if the node is in either set, so it can vote and start
+// elections.
+func (membership Membership) IsVoter(nodeId string) bool {
+	_, inNodes := membership.Nodes[nodeId]
+	_, inNewNodes := membership.NewNodes[nodeId]
+	return inNodes || inNewNodes
+}
+
+// IsLearner returns true if the node is a learner.
+func (membership Membership) IsLearner(nodeId string) bool {
+	_, ok := membership.Learners[nodeId]
+	return ok
+}
+
+// Host returns the host of the node, preferring the new set since it has the
+// latest address of a node in both sets.
+func (membership Membership) Host(nodeId string) (global.NodeHost, bool) {
+	if host, ok := membership.NewNodes[nodeId]; ok {
+		return host, true
+	} else if host, ok := membership.Nodes[nodeId]; ok {
+		return host, true
+	}
+	host, ok := membership.Learners[nodeId]
+	return host, ok
+}
+
+// HasQuorum returns true if the nodes for which voted returns true include a
+// majority of the nodes, and a majority of the new nodes if the membership is
+// joint.
+func (membership Membership) HasQuorum(voted func(nodeId string) bool) bool {
+	if !hasMajority(membership.Nodes, voted) {
+		return false
+	}
+	return !membership.IsJoint() || hasMajority(membership.NewNodes, voted)
+}
+
+// hasMajority returns true if voted returns true for a majority of the nodes.
+func hasMajority(nodes map[string]global.NodeHost, voted func(nodeId string) bool) bool {
+	count := 0
+	for nodeId := range nodes {
+		if voted(nodeId) {
+			count++
+		}
+	}
+	return len(nodes) > 0 && count > len(nodes)/2
+}
+
+// quorumIndex returns the highest log index that has been replicated on a
+// quorum of the nodes, given the highest index replicated on each node.
+func (membership Membership) quorumIndex(matchIndex func(nodeId string) uint32) uint32 {
+	index := majorityIndex(membership.Nodes, matchIndex)
+	if membership.IsJoint() {
+		if newIndex := majorityIndex(membership.NewNodes, matchIndex); newIndex < index {
+			index = newIndex
+		}
+	}
+	return index
+}
+
+// majorityIndex returns the highest log index that has been replicated on a
+// majority of the nodes.
+func majorityIndex(nodes map[string]global.NodeHost, matchIndex func(nodeId string) uint32) uint32 {
+	if len(nodes) == 0 {
+		return 0
+	}
+
+	matchIndices := make([]uint32, 0, len(nodes))
+	for nodeId := range nodes {
+		matchIndices = append(matchIndices, matchIndex(nodeId))
+	}
+
+	// After sorting in descending order, the entry at the majority position is
+	// the highest index replicated on a majority of the nodes.
+	sort.Slice(matchIndices, func(i, j int) bool { return matchIndices[i] > matchIndices[j] })
+	return matchIndices[len(matchIndices)/2]
+}
+
+// Membership returns the latest membership in the node's log, which takes
+// effect as soon as it is appended, even before it is committed.
+func (state *NodeState) Membership() Membership {
+	return state.membership
+}
+
+// MembershipChanged returns a channel that will be closed the next time the
+// membership changes. The lock should be held while calling it and checking
+// Membership so that no change is missed.
+func (state *NodeState) MembershipChanged() <-chan bool {
+	return state.membershipChanged
+}
+
+// MembershipIndex returns the index of the entry of the latest membership, or
+// the log offset if the membership came from a snapshot or was bootstrapped.
+func (state *NodeState) MembershipIndex() uint32 {
+	return state.membershipIndex
+}
+
+// MembershipCommitted returns true if the entry of the latest membership has
+// been committed.
+func (state *NodeState) MembershipCommitted() bool {
+	return state.membershipIndex <= state.CommitIndex
+}
+
+// Bootstrap sets the membership that a new cluster starts with, unless the
+// log or the latest snapshot already has a membership.
+func (state *NodeState) Bootstrap(nodes map[string]global.NodeHost) {
+	if state.baseMembership.Nodes != nil {
+		return
+	}
+	state.baseMembership = Membership{Nodes: nodes}
+	state.updateMembership()
+}
+
+// ChangeMembership starts moving the cluster to the given voters and learners
+// by appending a membership entry to the leader's log. If the voters change,
+// the entry has a joint membership, and once it is committed the leader
+// appends an entry with only the new voters. It returns the index and term of
+// the first entry.
+func (state *NodeState) ChangeMembership(nodes map[string]global.NodeHost, learners map[string]global.NodeHost) (uint32, uint32, error) {
+	if state.role != Leader {
+		return 0, 0, ErrNotLeader
+	} else if len(nodes) == 0 {
+		return 0, 0, ErrNoNodes
+	} else if state.membership.IsJoint() || !state.MembershipCommitted() {
+		// Only one membership change can be in progress at a time.
+		return 0, 0, ErrMembershipChangePending
+	} else if state.transferTarget != "" {
+		return 0, 0, ErrTransferInProgress
+	}
+
+	if len(learners) == 0 {
+		learners = nil
+	}
+
+	// Learners don't count toward quorum, so a change to only the learners
+	// takes effect without a joint membership.
+	if reflect.DeepEqual(nodes, state.membership.Nodes) {
+		return state.appendMembership(Membership{Nodes: nodes, Learners: learners})
+	}
+
+	joint := Membership{Nodes: state.membership.Nodes, NewNodes: nodes, Learners: learners}
+	return state.appendMembership(joint)
+}
+
+// CaughtUp returns true if the leader's log has been replicated to the node up
+// to within MaxPromotionLag entries of the end.
+func (state *NodeState) CaughtUp(nodeId string) bool {
+	return state.MatchIndex[nodeId]+MaxPromotionLag >= state.LogLength()
+}
+
+// appendMembership appends an entry with the membership to the leader's log.
+func (state *NodeState) appendMembership(membership Membership) (uint32, uint32, error) {
+	jsonValue, err := json.Marshal(membership)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	index, term, ok := state.AppendLeaderEntry(MembershipKey, string(jsonValue))
+	if !ok {
+		return 0, 0, ErrNotLeader
+	}
+	global.Log.Infof("Appended membership %v at index %d", membership.NodeIds(), index)
+	return index, term, nil
+}
+
+// advanceMembership is called by the leader when CommitIndex changes. Once a
+// joint membership is committed, it appends the new membership, and once the
+// new membership is committed, the leader steps down if it was removed. It
+// returns true if an entry was appended.
+func (state *NodeState) advanceMembership(leaderId string) bool {
+	if state.role != Leader || !state.MembershipCommitted() {
+		return false
+	}
+
+	if state.membership.IsJoint() {
+		newMembership := Membership{Nodes: state.membership.NewNodes, Learners: state.membership.Learners}
+		_, _, err := state.appendMembership(newMembership)
+		if err != nil {
+			global.Log.Error("Failed to append new membership:", err.Error())
+			return false
+		}
+		return true
+	}
+
+	if !state.membership.IsVoter(leaderId) {
+		global.Log.Info("Stepping down after being removed from the cluster")
+		state.LeaderId = ""
+		state.setRole(Follower)
+	}
+	return false
+}
+
+// membershipAt returns the latest membership in the log up to and including
+// the given index, and the index of its entry.
+func (state *NodeState) membershipAt(index uint32) (Membership, uint32) {
+	for i := index; i > state.logOffset; i-- {
+		entry := state.Log(i)
+		if entry.Key != MembershipKey {
+			continue
+		}
+
+		var membership Membership
+		err := json.Unmarshal([]byte(entry.Value), &membership)
+		if err != nil {
+			global.Log.Errorf("Invalid membership entry at index %d: %s", i, err.Error())
+			continue
+		}
+		return membership, i
+	}
+	return state.baseMembership, state.logOffset
+}
+
+// updateMembership sets the membership to the latest one in the log, and
+// notifies any goroutines waiting on MembershipChanged if it changed.
+func (state *NodeState) updateMembership() {
+	membership, index := state.membershipAt(state.LogLength())
+	state.setMembership(membership, index)
+}
+
+// setMembership sets the membership and the index of its entry.
+func (state *NodeState) setMembership(membership Membership, index uint32) {
+	state.membershipIndex = index
+	if reflect.DeepEqual(membership, state.membership) {
+		return
+	}
+
+	state.membership = membership
+	if state.role == Leader {
+		state.initPeerProgress()
+	}
+
+	close(state.membershipChanged)
+	state.membershipChanged = make(chan bool)
+}
+
+// initPeerProgress initializes NextIndex, MatchIndex, and LastContact for the
+// nodes in the membership that the leader isn't tracking yet.
+func (state *NodeState) initPeerProgress() {
+	for _, nodeId := range state.membership.NodeIds() {
+		if _, ok := state.NextIndex[nodeId]; !ok {
+			state.NextIndex[nodeId] = state.LogLength() + 1
+			state.MatchIndex[nodeId] = 0
+			state.LastContact[nodeId] = time.Now()
+		}
+	}
+}
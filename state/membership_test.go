@@ -0,0 +1,220 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func Test_HasQuorum_WithJointMembership_RequiresMajorityOfBothSets(t *testing.T) {
+	membership := Membership{
+		Nodes:    map[string]global.NodeHost{"1": {}, "2": {}, "3": {}},
+		NewNodes: map[string]global.NodeHost{"3": {}, "4": {}, "5": {}},
+	}
+
+	var tests = []struct {
+		votes     []string
+		hasQuorum bool
+	}{
+		// A majority of the old nodes only.
+		{[]string{"1", "2"}, false},
+		// A majority of the new nodes only.
+		{[]string{"3", "4", "5"}, false},
+		// A majority of both sets.
+		{[]string{"2", "3", "4"}, true},
+	}
+
+	for _, test := range tests {
+		votes := make(map[string]bool)
+		for _, nodeId := range test.votes {
+			votes[nodeId] = true
+		}
+
+		hasQuorum := membership.HasQuorum(func(nodeId string) bool { return votes[nodeId] })
+		if hasQuorum != test.hasQuorum {
+			t.Errorf("HasQuorum for votes %v was not %t", test.votes, test.hasQuorum)
+		}
+	}
+}
+
+func Test_quorumIndex_WithLearners_IgnoresLearners(t *testing.T) {
+	membership := Membership{
+		Nodes:    map[string]global.NodeHost{"1": {}, "2": {}, "3": {}},
+		Learners: map[string]global.NodeHost{"4": {}, "5": {}},
+	}
+	matchIndex := map[string]uint32{"1": 5, "2": 1, "3": 1, "4": 5, "5": 5}
+
+	index := membership.quorumIndex(func(nodeId string) uint32 { return matchIndex[nodeId] })
+	if index != 1 {
+		t.Error("Quorum index was not 1:", index)
+	}
+
+	if membership.IsVoter("4") || !membership.Contains("4") {
+		t.Error("Learner was a voter or was not contained in the membership")
+	}
+	if len(membership.VoterIds()) != 3 || len(membership.NodeIds()) != 5 {
+		t.Error("Voters and nodes were not 3 and 5:", membership.VoterIds(), membership.NodeIds())
+	}
+}
+
+func Test_ChangeMembership_WhenPromotingLearner_GoesThroughJointMembership(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	// Adding a learner doesn't change the voters, so it isn't joint and a
+	// single node cluster commits it right away.
+	learners := map[string]global.NodeHost{"2": {}}
+	index, _, err := node.ChangeMembership(map[string]global.NodeHost{"1": {}}, learners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.AdvanceCommitIndex("1")
+
+	if node.Membership().IsJoint() || !node.Membership().IsLearner("2") || node.CommitIndex != index {
+		t.Fatal("Learner was not added and committed:", node.Membership())
+	}
+	if _, ok := node.NextIndex["2"]; !ok {
+		t.Error("Leader did not start tracking the learner")
+	}
+
+	for node.LogLength() <= MaxPromotionLag {
+		node.AppendLeaderEntry("a", "A")
+	}
+	if node.CaughtUp("2") {
+		t.Error("Learner was caught up before it had any entries")
+	}
+	node.MatchIndex["2"] = node.LogLength() - MaxPromotionLag
+	if !node.CaughtUp("2") {
+		t.Error("Learner was not caught up when it was MaxPromotionLag entries behind")
+	}
+	node.AdvanceCommitIndex("1")
+
+	index, _, err = node.ChangeMembership(map[string]global.NodeHost{"1": {}, "2": {}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.Membership().IsJoint() || !node.Membership().IsVoter("2") || node.Membership().IsLearner("2") {
+		t.Error("Membership was not joint with node 2 as a voter:", node.Membership())
+	}
+}
+
+func Test_ChangeMembership_WhenSingleNodeLeader_MovesToNewNodesThroughJointMembership(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	newNodes := map[string]global.NodeHost{"1": {}, "2": {}}
+	index, _, err := node.ChangeMembership(newNodes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !node.Membership().IsJoint() {
+		t.Error("Membership was not joint after the change was appended:", node.Membership())
+	}
+
+	_, _, err = node.ChangeMembership(map[string]global.NodeHost{"1": {}}, nil)
+	if err != ErrMembershipChangePending {
+		t.Error("Second change was not rejected as pending:", err)
+	}
+
+	// Node 2 has to acknowledge the joint entry since it is in the new set.
+	node.MatchIndex["2"] = index
+	node.AdvanceCommitIndex("1")
+
+	if node.CommitIndex != index {
+		t.Errorf("CommitIndex was not %d: %d", index, node.CommitIndex)
+	}
+
+	// Committing the joint membership appends the new membership.
+	if node.LogLength() != index+1 || node.Log(index+1).Key != MembershipKey {
+		t.Fatal("New membership entry was not appended:", node.LogLength())
+	}
+	var appended Membership
+	json.Unmarshal([]byte(node.Log(index+1).Value), &appended)
+	if appended.IsJoint() || len(appended.Nodes) != 2 {
+		t.Error("Appended membership was not the new nodes:", appended)
+	}
+	if node.Membership().IsJoint() || node.MembershipCommitted() {
+		t.Error("Membership was not the uncommitted new nodes:", node.Membership())
+	}
+
+	node.MatchIndex["2"] = index + 1
+	node.AdvanceCommitIndex("1")
+
+	if !node.MembershipCommitted() {
+		t.Error("New membership was not committed")
+	}
+	if node.Role() != Leader {
+		t.Error("Leader in the new membership stepped down")
+	}
+}
+
+func Test_ChangeMembership_WhenLeaderIsRemoved_StepsDownOnceCommitted(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	index, _, err := node.ChangeMembership(map[string]global.NodeHost{"2": {}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.MatchIndex["2"] = index
+	node.AdvanceCommitIndex("1")
+	if node.Role() != Leader {
+		t.Fatal("Leader stepped down before the new membership was committed")
+	}
+
+	node.MatchIndex["2"] = index + 1
+	node.AdvanceCommitIndex("1")
+
+	if node.Role() != Follower {
+		t.Error("Removed leader did not step down:", node.Role())
+	}
+	if node.Membership().Contains("1") {
+		t.Error("Membership still contained the removed leader:", node.Membership())
+	}
+}
+
+func Test_TruncateLog_WithMembershipEntry_RevertsMembership(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+
+	jsonValue, _ := json.Marshal(Membership{Nodes: map[string]global.NodeHost{"1": {}, "2": {}}})
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{MembershipKey, string(jsonValue), 1})
+
+	if len(node.Membership().Nodes) != 2 {
+		t.Error("Appended membership did not take effect:", node.Membership())
+	}
+
+	membershipChanged := node.MembershipChanged()
+	node.TruncateLog(2)
+
+	if len(node.Membership().Nodes) != 3 {
+		t.Error("Membership was not reverted to the bootstrapped nodes:", node.Membership())
+	}
+	select {
+	case <-membershipChanged:
+	default:
+		t.Error("MembershipChanged was not closed")
+	}
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/json"
+	"io"
 	"strconv"
 )
 
@@ -21,11 +22,53 @@ func (sm MemoryDataStore) Put(key string, value string) error {
 	return nil
 }
 
+// PutAll adds the key-value pairs to the data store.
+func (sm MemoryDataStore) PutAll(values map[string]string) error {
+	for key, value := range values {
+		sm.values[key] = value
+	}
+	return nil
+}
+
 // Get retrieves a value based on its key.
 func (sm MemoryDataStore) Get(key string) (string, error) {
 	return sm.values[key], nil
 }
 
+// ForEach calls f with every key-value pair in the data store.
+func (sm MemoryDataStore) ForEach(f func(key string, value string) error) error {
+	for key, value := range sm.values {
+		err := f(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceAll replaces the contents of the data store with the key-value pairs
+// returned by next. The contents are left unchanged if next fails.
+func (sm MemoryDataStore) ReplaceAll(next func() (string, string, error)) error {
+	values := make(map[string]string)
+	for {
+		key, value, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+
+	for key := range sm.values {
+		delete(sm.values, key)
+	}
+	for key, value := range values {
+		sm.values[key] = value
+	}
+	return nil
+}
+
 // RetrieveLogEntries returns the LogEntries found between the specified indices.
 func (sm MemoryDataStore) RetrieveLogEntries(firstIndex int, lastIndex int) ([]LogEntry, error) {
 	entries := []LogEntry{}
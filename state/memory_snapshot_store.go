@@ -0,0 +1,117 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+// MemorySnapshotStore stores snapshots in memory.
+type MemorySnapshotStore struct {
+	mutex     sync.Mutex
+	snapshots map[string]memorySnapshot
+}
+
+// memorySnapshot is a snapshot stored in a MemorySnapshotStore.
+type memorySnapshot struct {
+	meta SnapshotMeta
+	data []byte
+}
+
+// NewMemorySnapshotStore constructs a new empty MemorySnapshotStore.
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[string]memorySnapshot)}
+}
+
+// Create starts a new snapshot that is buffered in memory.
+func (store *MemorySnapshotStore) Create(lastIncludedIndex uint32, lastIncludedTerm uint32, membership Membership) (SnapshotSink, error) {
+	meta := SnapshotMeta{
+		Id:                newSnapshotId(lastIncludedIndex, lastIncludedTerm),
+		LastIncludedIndex: lastIncludedIndex,
+		LastIncludedTerm:  lastIncludedTerm,
+		Membership:        membership,
+	}
+	return &memorySnapshotSink{store: store, meta: meta}, nil
+}
+
+// List returns the metadata of the stored snapshots, newest first.
+func (store *MemorySnapshotStore) List() ([]SnapshotMeta, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshots := make([]SnapshotMeta, 0, len(store.snapshots))
+	for _, snapshot := range store.snapshots {
+		snapshots = append(snapshots, snapshot.meta)
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+// Open returns the metadata and data of the snapshot with the given id.
+func (store *MemorySnapshotStore) Open(id string) (SnapshotMeta, SnapshotReader, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshot, ok := store.snapshots[id]
+	if !ok {
+		return SnapshotMeta{}, nil, errors.New("snapshot not found: " + id)
+	}
+	return snapshot.meta, memorySnapshotReader{bytes.NewReader(snapshot.data)}, nil
+}
+
+// Retain removes all but the newest count snapshots.
+func (store *MemorySnapshotStore) Retain(count int) error {
+	snapshots, _ := store.List()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for i := count; i < len(snapshots); i++ {
+		delete(store.snapshots, snapshots[i].Id)
+	}
+	return nil
+}
+
+// memorySnapshotReader reads the data of a snapshot in a MemorySnapshotStore.
+type memorySnapshotReader struct {
+	*bytes.Reader
+}
+
+// Close does nothing, since there is nothing to release.
+func (reader memorySnapshotReader) Close() error {
+	return nil
+}
+
+// memorySnapshotSink buffers a snapshot until it is closed.
+type memorySnapshotSink struct {
+	store  *MemorySnapshotStore
+	meta   SnapshotMeta
+	buffer bytes.Buffer
+}
+
+// Write appends snapshot data to the buffer.
+func (sink *memorySnapshotSink) Write(data []byte) (int, error) {
+	n, err := sink.buffer.Write(data)
+	sink.meta.Size += int64(n)
+	sink.meta.Checksum = UpdateChecksum(sink.meta.Checksum, data[:n])
+	return n, err
+}
+
+// Meta returns the metadata of the snapshot being written.
+func (sink *memorySnapshotSink) Meta() SnapshotMeta {
+	return sink.meta
+}
+
+// Close adds the buffered snapshot to the store.
+func (sink *memorySnapshotSink) Close() error {
+	sink.store.mutex.Lock()
+	defer sink.store.mutex.Unlock()
+
+	sink.store.snapshots[sink.meta.Id] = memorySnapshot{meta: sink.meta, data: sink.buffer.Bytes()}
+	return nil
+}
+
+// Cancel discards the buffered snapshot.
+func (sink *memorySnapshotSink) Cancel() error {
+	sink.buffer.Reset()
+	return nil
+}
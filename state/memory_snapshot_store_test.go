@@ -0,0 +1,36 @@
+package state
+
+import (
+	"testing"
+)
+
+func Test_MemorySnapshotStore_WithCreatedSnapshots_ListsNewestFirstAndRetains(t *testing.T) {
+	store := NewMemorySnapshotStore()
+	createSnapshot(t, store, 10, map[string]string{"a": "A"})
+	newest := createSnapshot(t, store, 20, map[string]string{"b": "B"})
+
+	err := store.Retain(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Id != newest.Id {
+		t.Fatal("Only the newest snapshot was not retained:", snapshots)
+	}
+
+	_, reader, err := store.Open(newest.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := readSnapshotData(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data["b"] != "B" {
+		t.Error("Snapshot data did not match:", data)
+	}
+}
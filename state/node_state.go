@@ -3,6 +3,8 @@ package state
 import (
 	"encoding/json"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/thomasylee/GoRaft/global"
 )
@@ -17,9 +19,11 @@ type LogEntry struct {
 
 // Define constants for important keys in the Bolt database.
 const (
-	currentTerm string = "CurrentTerm"
-	votedFor    string = "VotedFor"
-	logEntries  string = "LogEntries"
+	currentTerm   string = "CurrentTerm"
+	votedFor      string = "VotedFor"
+	logEntries    string = "LogEntries"
+	logOffset     string = "LogOffset"
+	logOffsetTerm string = "LogOffsetTerm"
 )
 
 // NodeState contains both the persistent and volatile state that a node
@@ -28,7 +32,12 @@ const (
 // Note that currentTerm, votedFor, and log are not exported because they need
 // to be persistent, so to preserve consistency, they should be updated and
 // retrieved using the respective NodeState methods.
+//
+// The RPC handlers and the main node loop run in different goroutines, so the
+// embedded mutex must be held while reading or updating the state.
 type NodeState struct {
+	sync.Mutex
+
 	// The latest term the node has seen.
 	currentTerm uint32
 
@@ -39,25 +48,102 @@ type NodeState struct {
 	// when the entry was received from the leader.
 	log *[]LogEntry
 
+	// The index and term of the last entry removed from the front of the log
+	// by compaction. The first entry in log has index logOffset + 1.
+	logOffset     uint32
+	logOffsetTerm uint32
+
+	// The index of the last entry included in the latest snapshot.
+	snapshotIndex uint32
+
+	// The latest membership in the log and the index of its entry. The base
+	// membership is the one as of the log offset, which comes from the latest
+	// snapshot or from Bootstrap.
+	membership      Membership
+	membershipIndex uint32
+	baseMembership  Membership
+
+	// Channel that is closed and replaced whenever the membership changes.
+	membershipChanged chan bool
+
+	// The node's role in the cluster, which should only be changed using the
+	// transition methods such as BecomeCandidate and StepDown.
+	role Role
+
+	// Channel that is closed and replaced whenever the role changes.
+	roleChanged chan bool
+
 	// The node id of the current leader.
 	LeaderId string
 
+	// When the node last heard from the leader of its current term.
+	leaderContact time.Time
+
 	// Node state and stored state are stored by different state machines.
 	NodeDataStore    DataStore
 	StorageDataStore DataStore
 
-	// Index of the highest log entry known to be committed.
+	// Snapshots of the storage state machine, which replace the log entries
+	// they include.
+	SnapshotStore SnapshotStore
+
+	// Index of the highest log entry known to be committed. It should be
+	// updated using SetCommitIndex so that the applier is notified.
 	CommitIndex uint32
 
 	// Index of the highest log entry applied to this node's storage state machine.
+	// It should be updated using SetLastApplied so that waiters are notified.
 	LastApplied uint32
 
+	// Channels that are closed and replaced whenever CommitIndex or LastApplied
+	// change, so that any number of goroutines can wait for the change.
+	commitIndexChanged chan bool
+	lastAppliedChanged chan bool
+
+	// (Leader only) Channel that is closed and replaced whenever the leader
+	// appends new entries to its log.
+	entriesAppended chan bool
+
 	// (Leader only) For each node, the index of the next log entry to send to.
 	NextIndex map[string]uint32
 
 	// (Leader only) For each node, the index of the highest log entry known to be replicated on
 	// the node.
 	MatchIndex map[string]uint32
+
+	// (Leader only) For each node, when the leader last received a response
+	// from the node.
+	LastContact map[string]time.Time
+
+	// (Leader only) The node that leadership is being transferred to, if any,
+	// and whether it has been told to start an election.
+	transferTarget string
+	timeoutNowSent bool
+
+	// (Leader only) The newest read round and whether a request has been sent
+	// for it, the newest round that each node has acknowledged, and the
+	// newest round that a quorum has confirmed.
+	readRound          uint32
+	readRoundSent      bool
+	readAcks           map[string]uint32
+	confirmedReadRound uint32
+
+	// (Leader only) Channels that are closed and replaced whenever a read
+	// round is started or confirmed.
+	readRequested      chan bool
+	readRoundConfirmed chan bool
+
+	// (Leader only) For each node, when the newest request that the node
+	// responded to was sent, whether the lease was given up by telling
+	// another node to start an election, and the time before which requests
+	// don't count toward the lease.
+	leaseAcks    map[string]time.Time
+	leaseRevoked bool
+	leaseStart   time.Time
+
+	// Whether the node is a candidate because the leader transferred
+	// leadership to it.
+	transferElection bool
 }
 
 // Node contains the state of the currently running host node.
@@ -78,14 +164,18 @@ func GetNodeState() *NodeState {
 	if err != nil {
 		global.Log.Panic("Failed to initialize storageDataStore:", err.Error())
 	}
-	Node = NewNodeState(nodeDataStore, storageDataStore)
+	snapshotStore, err := NewFileSnapshotStore("snapshots")
+	if err != nil {
+		global.Log.Panic("Failed to initialize snapshotStore:", err.Error())
+	}
+	Node = NewNodeState(nodeDataStore, storageDataStore, snapshotStore)
 	return Node
 }
 
 // NewNodeState returns a NodeState based on values in the node state Bolt
-// database, using default values if the database does not exist or have any
-// values in it.
-func NewNodeState(nodeDataStore DataStore, storageDataStore DataStore) *NodeState {
+// database and the latest snapshot, using default values if the database does
+// not exist or have any values in it.
+func NewNodeState(nodeDataStore DataStore, storageDataStore DataStore, snapshotStore SnapshotStore) *NodeState {
 	var currentTermValue uint32
 	retrievedCurrentTerm, err := nodeDataStore.Get(currentTerm)
 	if err != nil {
@@ -106,7 +196,16 @@ func NewNodeState(nodeDataStore DataStore, storageDataStore DataStore) *NodeStat
 		global.Log.Panic("Failed to retrieve VotedFor:", err.Error())
 	}
 
-	logEntries, err := nodeDataStore.RetrieveLogEntries(1, 1000000)
+	// Entries covered by a snapshot have been removed from the log, so only
+	// the entries after the log offset need to be loaded.
+	logOffsetValue := retrieveUint32(nodeDataStore, logOffset)
+	logOffsetTermValue := retrieveUint32(nodeDataStore, logOffsetTerm)
+	snapshot, err := latestSnapshot(snapshotStore)
+	if err != nil {
+		global.Log.Panic("Failed to retrieve snapshot:", err.Error())
+	}
+
+	logEntries, err := nodeDataStore.RetrieveLogEntries(int(logOffsetValue)+1, int(logOffsetValue)+1000000)
 	if err != nil {
 		global.Log.Panic("Failed to retrieve log entries:", err.Error())
 	}
@@ -114,16 +213,61 @@ func NewNodeState(nodeDataStore DataStore, storageDataStore DataStore) *NodeStat
 
 	var node *NodeState
 	node = &NodeState{
-		NodeDataStore:    nodeDataStore,
-		StorageDataStore: storageDataStore,
+		NodeDataStore:      nodeDataStore,
+		StorageDataStore:   storageDataStore,
+		SnapshotStore:      snapshotStore,
+		roleChanged:        make(chan bool),
+		commitIndexChanged: make(chan bool),
+		lastAppliedChanged: make(chan bool),
+		entriesAppended:    make(chan bool),
+		membershipChanged:  make(chan bool),
+		readRequested:      make(chan bool),
+		readRoundConfirmed: make(chan bool),
 	}
 	node.SetCurrentTerm(currentTermValue)
 	node.SetVotedFor(votedForValue)
 	node.log = &logEntries
+	node.logOffset = logOffsetValue
+	node.logOffsetTerm = logOffsetTermValue
+
+	// Everything in the snapshot was committed, and the applier loads the
+	// snapshot into the storage data store before applying later entries. A
+	// crash while a snapshot was being installed can leave a log that doesn't
+	// reach the snapshot, which is discarded as the install would have done.
+	if snapshot != nil {
+		if !node.logContains(snapshot.LastIncludedIndex, snapshot.LastIncludedTerm) {
+			err = node.discardLog(snapshot.LastIncludedIndex, snapshot.LastIncludedTerm, snapshot.Membership)
+			if err != nil {
+				global.Log.Panic("Failed to discard log:", err.Error())
+			}
+		}
+		node.snapshotIndex = snapshot.LastIncludedIndex
+		node.CommitIndex = snapshot.LastIncludedIndex
+		node.baseMembership = snapshot.Membership
+	}
+	node.updateMembership()
 
 	return node
 }
 
+// retrieveUint32 returns the uint32 value stored for the key in the data
+// store, or 0 if there is no value.
+func retrieveUint32(dataStore DataStore, key string) uint32 {
+	retrievedValue, err := dataStore.Get(key)
+	if err != nil {
+		global.Log.Panicf("Failed to retrieve %s: %s", key, err.Error())
+	}
+	if retrievedValue == "" {
+		return 0
+	}
+
+	value, err := strconv.Atoi(retrievedValue)
+	if err != nil {
+		global.Log.Panicf("Failed to convert %s to int: %s", key, err.Error())
+	}
+	return uint32(value)
+}
+
 // SetCurrentTerm sets the current term in memory and in the node state machine.
 func (state *NodeState) SetCurrentTerm(newCurrentTerm uint32) {
 	state.currentTerm = newCurrentTerm
@@ -151,30 +295,284 @@ func (state *NodeState) VotedFor() string {
 // Note that this method does not do any safety checking to prevent overwriting
 // existing entries; that check should be done by the caller beforehand.
 func (state *NodeState) SetLogEntry(index uint32, entry LogEntry) error {
-	jsonValue, err := json.Marshal(entry)
-	if err != nil {
-		return err
+	return state.SetLogEntries(index, []LogEntry{entry})
+}
+
+// SetLogEntries sets the entries in the NodeState's log at consecutive indices
+// starting at the given index, writing them to the data store in a single
+// transaction. Like SetLogEntry, it does not check for existing entries.
+func (state *NodeState) SetLogEntries(index uint32, entries []LogEntry) error {
+	values := make(map[string]string, len(entries))
+	membershipChanged := false
+	for i, entry := range entries {
+		jsonValue, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		values[strconv.Itoa(int(index)+i)] = string(jsonValue)
+		membershipChanged = membershipChanged || entry.Key == MembershipKey
 	}
 
-	err = state.NodeDataStore.Put(strconv.Itoa(int(index)), string(jsonValue))
+	err := state.NodeDataStore.PutAll(values)
 	if err != nil {
 		return err
 	}
 
-	for i := uint32(len(*state.log)); i < index-1; i++ {
+	for i := state.LogLength(); i < index-1; i++ {
 		*state.log = append(*state.log, LogEntry{})
 	}
-	*state.log = append(*state.log, entry)
+	*state.log = append(*state.log, entries...)
+
+	if membershipChanged {
+		state.updateMembership()
+	}
 	return nil
 }
 
-// LogLength returns the number of entries in the node's log.
-func (state NodeState) LogLength() uint32 {
-	return uint32(len(*state.log))
+// AppendLeaderEntry appends a new entry for the key-value pair to the log with
+// the current term if the node is the leader, notifying any goroutines waiting
+// on EntriesAppended. It returns the index and term of the new entry, or false
+// if the node is not the leader.
+func (state *NodeState) AppendLeaderEntry(key string, value string) (uint32, uint32, bool) {
+	return state.AppendLeaderEntries([]LogEntry{{Key: key, Value: value}})
+}
+
+// AppendLeaderEntries appends the entries to the log with the current term in
+// a single write if the node is the leader, notifying any goroutines waiting
+// on EntriesAppended once. It returns the index of the first new entry and
+// the term, or false if the node is not the leader.
+func (state *NodeState) AppendLeaderEntries(entries []LogEntry) (uint32, uint32, bool) {
+	if state.role != Leader {
+		return 0, 0, false
+	}
+
+	for i := range entries {
+		entries[i].Term = state.currentTerm
+	}
+	index := state.LogLength() + 1
+	err := state.SetLogEntries(index, entries)
+	if err != nil {
+		global.Log.Error("Failed to append log entries:", err.Error())
+		return 0, 0, false
+	}
+
+	close(state.entriesAppended)
+	state.entriesAppended = make(chan bool)
+	return index, state.currentTerm, true
+}
+
+// EntriesAppended returns a channel that will be closed the next time the
+// leader appends entries to its log. The lock should be held while calling it
+// and checking the log length so that no change is missed.
+func (state *NodeState) EntriesAppended() <-chan bool {
+	return state.entriesAppended
+}
+
+// TruncateLog removes the log entry at the given index and all the entries
+// that follow it from the NodeState's log. The index must be after the log
+// offset, since compacted entries can't be removed.
+func (state *NodeState) TruncateLog(index uint32) error {
+	for i := index; i <= state.LogLength(); i++ {
+		err := state.NodeDataStore.Put(strconv.Itoa(int(i)), "")
+		if err != nil {
+			return err
+		}
+	}
+
+	if index <= state.LogLength() {
+		*state.log = (*state.log)[:index-state.logOffset-1]
+	}
+
+	// A membership entry that was removed no longer applies.
+	if state.membershipIndex >= index {
+		state.updateMembership()
+	}
+	return nil
+}
+
+// AdvanceCommitIndex sets CommitIndex to the highest log index that has been
+// replicated on a quorum of the membership, based on MatchIndex for the
+// followers and the log length for the leader itself. Only entries from the
+// current term are committed by counting replicas; earlier entries are
+// committed along with them. It returns true if CommitIndex changed.
+func (state *NodeState) AdvanceCommitIndex(leaderId string) bool {
+	quorumIndex := state.membership.quorumIndex(func(nodeId string) uint32 {
+		if nodeId == leaderId {
+			return state.LogLength()
+		}
+		return state.MatchIndex[nodeId]
+	})
+
+	if quorumIndex <= state.CommitIndex || state.LogTerm(quorumIndex) != state.currentTerm {
+		return false
+	}
+
+	state.SetCommitIndex(quorumIndex)
+
+	// Committing a joint membership appends the new membership, which a
+	// single node cluster can commit right away.
+	if state.advanceMembership(leaderId) {
+		state.AdvanceCommitIndex(leaderId)
+	}
+	return true
+}
+
+// SetCommitIndex sets CommitIndex and notifies any goroutines waiting on
+// CommitIndexChanged.
+func (state *NodeState) SetCommitIndex(index uint32) {
+	state.CommitIndex = index
+	global.Log.Debugf("CommitIndex updated: %d", index)
+
+	close(state.commitIndexChanged)
+	state.commitIndexChanged = make(chan bool)
+}
+
+// CommitIndexChanged returns a channel that will be closed the next time
+// CommitIndex changes. The lock should be held while calling it and checking
+// CommitIndex so that no change is missed.
+func (state *NodeState) CommitIndexChanged() <-chan bool {
+	return state.commitIndexChanged
+}
+
+// SetLastApplied sets LastApplied and notifies any goroutines waiting on
+// LastAppliedChanged.
+func (state *NodeState) SetLastApplied(index uint32) {
+	state.LastApplied = index
+
+	close(state.lastAppliedChanged)
+	state.lastAppliedChanged = make(chan bool)
+}
+
+// LastAppliedChanged returns a channel that will be closed the next time
+// LastApplied changes. The lock should be held while calling it and checking
+// LastApplied so that no change is missed.
+func (state *NodeState) LastAppliedChanged() <-chan bool {
+	return state.lastAppliedChanged
+}
+
+// WaitForLastApplied blocks until LastApplied reaches the given index,
+// returning true, or until cancel is closed, returning false. The lock must
+// not be held by the caller.
+func (state *NodeState) WaitForLastApplied(index uint32, cancel <-chan struct{}) bool {
+	for {
+		state.Lock()
+		lastApplied := state.LastApplied
+		changed := state.lastAppliedChanged
+		state.Unlock()
+
+		if lastApplied >= index {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-cancel:
+			return false
+		}
+	}
+}
+
+// ApplyCommittedEntries applies the log entries after LastApplied up to
+// CommitIndex to the storage data store in order, advancing LastApplied after
+// each one. Entries without a key are no-op entries and are skipped, as are
+// membership entries. If the entries after LastApplied have been replaced by a
+// snapshot, the snapshot is loaded into the storage data store instead.
+//
+// The lock must not be held by the caller, since it is released while writing
+// to the storage data store.
+func (state *NodeState) ApplyCommittedEntries() error {
+	state.Lock()
+	if state.LastApplied < state.snapshotIndex {
+		state.Unlock()
+		return state.restoreSnapshot()
+	}
+
+	firstIndex := state.LastApplied + 1
+	entries := []LogEntry{}
+	for i := firstIndex; i <= state.CommitIndex; i++ {
+		entries = append(entries, state.Log(i))
+	}
+	state.Unlock()
+
+	for i, entry := range entries {
+		if entry.Key != "" && entry.Key != MembershipKey {
+			err := state.StorageDataStore.Put(entry.Key, entry.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		state.Lock()
+		state.SetLastApplied(firstIndex + uint32(i))
+		state.Unlock()
+	}
+	return nil
+}
+
+// LogLength returns the index of the last entry in the node's log, including
+// the entries that have been removed by compaction.
+func (state *NodeState) LogLength() uint32 {
+	return state.logOffset + uint32(len(*state.log))
+}
+
+// LogOffset returns the index of the last entry removed from the log by
+// compaction, or 0 if the log has not been compacted.
+func (state *NodeState) LogOffset() uint32 {
+	return state.logOffset
+}
+
+// Log returns the LogEntry at the specified index, which must be after the
+// log offset. Note that log indices start at 1, but the slice indices start
+// at 0.
+func (state *NodeState) Log(index uint32) LogEntry {
+	return (*state.log)[index-state.logOffset-1]
+}
+
+// LogTerm returns the term of the entry at the specified index, which may be
+// the last entry removed by compaction, or 0 for index 0.
+func (state *NodeState) LogTerm(index uint32) uint32 {
+	if index == 0 {
+		return 0
+	} else if index == state.logOffset {
+		return state.logOffsetTerm
+	}
+	return state.Log(index).Term
+}
+
+// TermStartIndex returns the index of the first entry in the log with the
+// same term as the entry at the specified index. Entries removed by compaction
+// are not considered, so the result is never before the first entry still in
+// the log.
+func (state *NodeState) TermStartIndex(index uint32) uint32 {
+	term := state.LogTerm(index)
+	for index > state.logOffset+1 && state.Log(index-1).Term == term {
+		index--
+	}
+	return index
+}
+
+// LastIndexOfTerm returns the index of the last entry at or before the
+// specified index that has the given term, and false if there is no such
+// entry in the log.
+func (state *NodeState) LastIndexOfTerm(term uint32, index uint32) (uint32, bool) {
+	if index > state.LogLength() {
+		index = state.LogLength()
+	}
+	// Terms never decrease along the log, so the search can stop at the first
+	// entry with an older term.
+	for ; index >= state.logOffset && index > 0; index-- {
+		entryTerm := state.LogTerm(index)
+		if entryTerm == term {
+			return index, true
+		} else if entryTerm < term {
+			break
+		}
+	}
+	return 0, false
 }
 
-// Log returns the LogEntry at the specified index. Note that log indices start
-// at 1, but the slice indices start at 0.
-func (state NodeState) Log(index uint32) LogEntry {
-	return (*state.log)[index-1]
+// LastLogTerm returns the term of the last entry in the node's log, or 0 if
+// the log is empty.
+func (state *NodeState) LastLogTerm() uint32 {
+	return state.LogTerm(state.LogLength())
 }
@@ -8,7 +8,7 @@ import (
 )
 
 func createNodeState() *NodeState {
-	return NewNodeState(NewMemoryDataStore(), NewMemoryDataStore())
+	return NewNodeState(NewMemoryDataStore(), NewMemoryDataStore(), NewMemorySnapshotStore())
 }
 
 func Test_SetLogEntry_WithValidNodeAndParams_SetsEntryInMemAndDataStore(t *testing.T) {
@@ -85,3 +85,191 @@ func Test_SetLogEntry_WithExistingIndex_SetsEntryInMemAndDataStore(t *testing.T)
 		}
 	}
 }
+
+func Test_TruncateLog_WithExistingIndex_RemovesEntriesFromMemAndDataStore(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.log = &[]LogEntry{}
+
+	node.SetLogEntry(1, LogEntry{"a", "A", 0})
+	node.SetLogEntry(2, LogEntry{"b", "B", 1})
+	node.SetLogEntry(3, LogEntry{"c", "C", 1})
+
+	err := node.TruncateLog(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.LogLength() != 1 {
+		t.Error("LogLength was not 1:", node.LogLength())
+	}
+
+	for _, index := range []string{"2", "3"} {
+		jsonInSM, err := node.NodeDataStore.Get(index)
+		if err != nil {
+			t.Errorf("Error processing entry %s: %s", index, err.Error())
+		} else if jsonInSM != "" {
+			t.Error("Log entry JSON in state machine was not removed:", index, jsonInSM)
+		}
+	}
+}
+
+func Test_AdvanceCommitIndex_WithMajorityMatchIndex_UpdatesCommitIndex(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	var tests = []struct {
+		matchIndex  map[string]uint32
+		commitIndex uint32
+	}{
+		// Only the leader has the entries.
+		{map[string]uint32{"2": 0, "3": 0}, 0},
+		// Entry 2 is from an earlier term, so it can't be committed by counting.
+		{map[string]uint32{"2": 2, "3": 0}, 0},
+		// Entry 3 is from the current term and is on a majority of the nodes.
+		{map[string]uint32{"2": 3, "3": 1}, 3},
+		// Entry 4 is on all of the nodes.
+		{map[string]uint32{"2": 4, "3": 4}, 4},
+	}
+
+	for _, test := range tests {
+		node := createNodeState()
+		node.log = &[]LogEntry{}
+		node.SetCurrentTerm(2)
+		node.SetLogEntry(1, LogEntry{"a", "A", 1})
+		node.SetLogEntry(2, LogEntry{"b", "B", 1})
+		node.SetLogEntry(3, LogEntry{"c", "C", 2})
+		node.SetLogEntry(4, LogEntry{"d", "D", 2})
+		node.MatchIndex = test.matchIndex
+		node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+
+		node.AdvanceCommitIndex("1")
+
+		if node.CommitIndex != test.commitIndex {
+			t.Errorf("CommitIndex for MatchIndex %v was not %d: %d", test.matchIndex, test.commitIndex, node.CommitIndex)
+		}
+	}
+}
+
+func Test_ApplyCommittedEntries_WithCommittedEntries_AppliesEntriesToStorage(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.log = &[]LogEntry{}
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{"", "", 1})
+	node.SetLogEntry(3, LogEntry{"b", "B", 1})
+	node.SetLogEntry(4, LogEntry{"c", "C", 1})
+
+	changed := node.LastAppliedChanged()
+	node.SetCommitIndex(3)
+
+	err := node.ApplyCommittedEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	default:
+		t.Error("LastAppliedChanged was not notified")
+	}
+
+	if node.LastApplied != 3 {
+		t.Error("LastApplied was not 3:", node.LastApplied)
+	}
+
+	var tests = []struct {
+		key   string
+		value string
+	}{
+		{"a", "A"},
+		{"b", "B"},
+		// Entry 4 has not been committed yet.
+		{"c", ""},
+	}
+
+	for _, test := range tests {
+		value, err := node.StorageDataStore.Get(test.key)
+		if err != nil {
+			t.Errorf("Error retrieving key %s: %s", test.key, err.Error())
+		} else if value != test.value {
+			t.Errorf("Value for key %s was not %q: %q", test.key, test.value, value)
+		}
+	}
+}
+
+func Test_TermStartIndexAndLastIndexOfTerm_WithSeveralTerms_FindTermBoundaries(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	for index, term := range []uint32{1, 1, 3, 3, 3, 4} {
+		node.SetLogEntry(uint32(index+1), LogEntry{"a", "A", term})
+	}
+
+	if index := node.TermStartIndex(5); index != 3 {
+		t.Error("Start of term 3 was not 3:", index)
+	}
+	if index := node.TermStartIndex(2); index != 1 {
+		t.Error("Start of term 1 was not 1:", index)
+	}
+
+	var tests = []struct {
+		term  uint32
+		index uint32
+		found uint32
+		ok    bool
+	}{
+		{3, 6, 5, true},
+		{3, 4, 4, true},
+		{1, 6, 2, true},
+		// There are no entries with term 2.
+		{2, 6, 0, false},
+		{5, 6, 0, false},
+	}
+
+	for _, test := range tests {
+		found, ok := node.LastIndexOfTerm(test.term, test.index)
+		if found != test.found || ok != test.ok {
+			t.Errorf("LastIndexOfTerm(%d, %d) was not %d, %t: %d, %t", test.term, test.index, test.found, test.ok, found, ok)
+		}
+	}
+}
+
+func Test_AppendLeaderEntries_WhenLeader_AppendsEntriesWithCurrentTerm(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+	entriesAppended := node.EntriesAppended()
+
+	index, appendedTerm, ok := node.AppendLeaderEntries([]LogEntry{{Key: "a", Value: "A"}, {Key: "b", Value: "B"}})
+	if !ok {
+		t.Fatal("AppendLeaderEntries returned false")
+	}
+
+	if index != 1 || appendedTerm != term || node.LogLength() != 2 {
+		t.Error("Entries were not appended at index 1 in the current term:", index, appendedTerm, node.LogLength())
+	}
+	if node.Log(2).Key != "b" || node.Log(2).Term != term {
+		t.Error("Second entry was not b in the current term:", node.Log(2))
+	}
+
+	entries, _ := node.NodeDataStore.RetrieveLogEntries(1, 2)
+	if len(entries) != 2 || entries[1].Value != "B" {
+		t.Error("Entries were not written to the data store:", entries)
+	}
+
+	select {
+	case <-entriesAppended:
+	default:
+		t.Error("EntriesAppended was not closed")
+	}
+}
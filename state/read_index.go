@@ -0,0 +1,99 @@
+package state
+
+// Reads are linearizable if the leader confirms that it is still the leader
+// after the read arrives, by hearing from a quorum in response to requests
+// sent after that point. Reads that arrive before the leader has sent such a
+// request share a read round, and every request the leader sends carries the
+// newest round, so any number of reads are confirmed by a single round of
+// heartbeats.
+
+// CommittedInTerm returns true if the leader has committed an entry in its
+// current term, which it needs to have done before its CommitIndex is known to
+// include every committed entry.
+func (state *NodeState) CommittedInTerm() bool {
+	return state.LogTerm(state.CommitIndex) == state.currentTerm
+}
+
+// StartReadRound returns the read round that confirms leadership for a read
+// arriving now, starting a new round if a request was already sent for the
+// newest one. Goroutines waiting on ReadRequested are notified of a new round.
+func (state *NodeState) StartReadRound(leaderId string) uint32 {
+	if state.readRoundSent {
+		state.readRound++
+		state.readRoundSent = false
+		close(state.readRequested)
+		state.readRequested = make(chan bool)
+
+		// A single node cluster confirms the round right away.
+		state.advanceConfirmedReadRound(leaderId)
+	}
+	return state.readRound
+}
+
+// ReadRoundForRequest returns the read round that a request being sent to a
+// follower confirms when the follower responds.
+func (state *NodeState) ReadRoundForRequest() uint32 {
+	state.readRoundSent = true
+	return state.readRound
+}
+
+// AckReadRound records that the node responded to a request for the read
+// round in the leader's term, and notifies goroutines waiting on
+// ReadRoundConfirmed if a quorum has now confirmed a newer round.
+func (state *NodeState) AckReadRound(nodeId string, round uint32, leaderId string) {
+	if state.role != Leader {
+		return
+	}
+	if round > state.readAcks[nodeId] {
+		state.readAcks[nodeId] = round
+	}
+	state.advanceConfirmedReadRound(leaderId)
+}
+
+// ReadRound returns the newest read round.
+func (state *NodeState) ReadRound() uint32 {
+	return state.readRound
+}
+
+// ConfirmedReadRound returns the newest read round that a quorum has
+// confirmed.
+func (state *NodeState) ConfirmedReadRound() uint32 {
+	return state.confirmedReadRound
+}
+
+// ReadRequested returns a channel that will be closed the next time a read
+// round is started.
+func (state *NodeState) ReadRequested() <-chan bool {
+	return state.readRequested
+}
+
+// ReadRoundConfirmed returns a channel that will be closed the next time a
+// newer read round is confirmed.
+func (state *NodeState) ReadRoundConfirmed() <-chan bool {
+	return state.readRoundConfirmed
+}
+
+// advanceConfirmedReadRound updates the confirmed read round to the newest
+// one that a quorum has acknowledged.
+func (state *NodeState) advanceConfirmedReadRound(leaderId string) {
+	round := state.membership.quorumIndex(func(nodeId string) uint32 {
+		if nodeId == leaderId {
+			return state.readRound
+		}
+		return state.readAcks[nodeId]
+	})
+	if round > state.confirmedReadRound {
+		state.confirmedReadRound = round
+		close(state.readRoundConfirmed)
+		state.readRoundConfirmed = make(chan bool)
+	}
+}
+
+// resetReadRounds discards the read rounds of an earlier term when the node
+// becomes leader.
+func (state *NodeState) resetReadRounds() {
+	state.readRound = 0
+	state.readRoundSent = true
+	state.confirmedReadRound = 0
+	state.readAcks = make(map[string]uint32)
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func Test_StartReadRound_WithConcurrentReads_SharesRoundUntilRequestIsSent(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+	readRequested := node.ReadRequested()
+
+	first := node.StartReadRound("1")
+	if node.StartReadRound("1") != first {
+		t.Error("Reads before a request was sent did not share a round")
+	}
+	select {
+	case <-readRequested:
+	default:
+		t.Error("ReadRequested was not closed")
+	}
+
+	sent := node.ReadRoundForRequest()
+	if sent != first {
+		t.Errorf("Request did not carry round %d: %d", first, sent)
+	}
+	second := node.StartReadRound("1")
+	if second <= first {
+		t.Error("Read after the request was sent did not start a new round:", second)
+	}
+
+	// The leader and node 2 form a quorum.
+	readRoundConfirmed := node.ReadRoundConfirmed()
+	node.AckReadRound("2", sent, "1")
+	if node.ConfirmedReadRound() != first {
+		t.Errorf("Confirmed round was not %d: %d", first, node.ConfirmedReadRound())
+	}
+	select {
+	case <-readRoundConfirmed:
+	default:
+		t.Error("ReadRoundConfirmed was not closed")
+	}
+
+	// A response to the earlier request can't confirm the newer round.
+	node.AckReadRound("3", sent, "1")
+	if node.ConfirmedReadRound() >= second {
+		t.Error("Newer round was confirmed by a request sent before it started")
+	}
+}
+
+func Test_StartReadRound_WhenSingleNodeLeader_ConfirmsRoundRightAway(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	if node.CommittedInTerm() {
+		t.Error("Leader had committed an entry in its term before appending one")
+	}
+	node.AppendLeaderEntry("", "")
+	node.AdvanceCommitIndex("1")
+	if !node.CommittedInTerm() {
+		t.Error("Leader had not committed an entry in its term")
+	}
+
+	round := node.StartReadRound("1")
+	if node.ConfirmedReadRound() != round {
+		t.Errorf("Round %d was not confirmed: %d", round, node.ConfirmedReadRound())
+	}
+}
@@ -0,0 +1,172 @@
+package state
+
+import (
+	"time"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+// Role is the part that a node plays in the Raft cluster.
+type Role int
+
+const (
+	// Followers respond to requests from leaders and candidates.
+	Follower Role = iota
+
+	// Candidates request votes to become the leader for a new term.
+	Candidate
+
+	// The leader handles client requests and replicates its log to the
+	// followers.
+	Leader
+)
+
+// String returns the name of the role.
+func (role Role) String() string {
+	switch role {
+	case Follower:
+		return "Follower"
+	case Candidate:
+		return "Candidate"
+	case Leader:
+		return "Leader"
+	}
+	return "Unknown"
+}
+
+// Role returns the node's current role.
+func (state *NodeState) Role() Role {
+	return state.role
+}
+
+// RoleChanged returns a channel that will be closed the next time the node's
+// role changes, when the node starts a new term as a candidate, or when a
+// follower hears from a new leader. The lock should be held while calling it and checking Role so
+// that no change is missed.
+func (state *NodeState) RoleChanged() <-chan bool {
+	return state.roleChanged
+}
+
+// setRole sets the node's role and notifies any goroutines waiting on
+// RoleChanged.
+func (state *NodeState) setRole(role Role) {
+	if state.role == role {
+		return
+	}
+
+	global.Log.Infof("Role changed from %s to %s in term %d", state.role, role, state.currentTerm)
+	state.role = role
+	state.notifyRoleChanged()
+}
+
+// notifyRoleChanged notifies any goroutines waiting on RoleChanged.
+func (state *NodeState) notifyRoleChanged() {
+	close(state.roleChanged)
+	state.roleChanged = make(chan bool)
+}
+
+// BecomeFollower makes the node a follower of the given leader for the
+// current term, recording that the leader has just been heard from.
+func (state *NodeState) BecomeFollower(leaderId string) {
+	if state.role == Follower && state.LeaderId != leaderId {
+		state.notifyRoleChanged()
+	}
+	state.LeaderId = leaderId
+	if leaderId != "" {
+		state.leaderContact = time.Now()
+	}
+	state.setRole(Follower)
+}
+
+// HeardFromLeader returns true if the node is the leader, or if it has heard
+// from the leader of its current term within the given duration.
+func (state *NodeState) HeardFromLeader(within time.Duration) bool {
+	if state.role == Leader {
+		return true
+	}
+	return state.LeaderId != "" && time.Since(state.leaderContact) < within
+}
+
+// StepDown makes the node a follower if the given term is newer than its
+// current term, updating the current term and clearing its vote. It returns
+// true if the term was newer.
+func (state *NodeState) StepDown(term uint32) bool {
+	if term <= state.currentTerm {
+		return false
+	}
+
+	state.SetCurrentTerm(term)
+	state.SetVotedFor("")
+	state.LeaderId = ""
+	state.setRole(Follower)
+	return true
+}
+
+// BecomeCandidate starts a new term with the node as a candidate that has
+// voted for itself, returning the new term. Leaders can't become candidates,
+// so ok is false if the node is the leader.
+func (state *NodeState) BecomeCandidate(nodeId string) (term uint32, ok bool) {
+	if state.role == Leader {
+		return state.currentTerm, false
+	}
+
+	state.SetCurrentTerm(state.currentTerm + 1)
+	state.SetVotedFor(nodeId)
+	state.LeaderId = ""
+	state.transferElection = false
+	if state.role == Candidate {
+		// The election for the previous term is abandoned for the new one.
+		state.notifyRoleChanged()
+	}
+	state.setRole(Candidate)
+	return state.currentTerm, true
+}
+
+// BecomeLeader makes the node the leader if it is still a candidate in the
+// given term, initializing NextIndex and MatchIndex for the given nodes. It
+// returns true if the node became leader.
+func (state *NodeState) BecomeLeader(term uint32, leaderId string, nodeIds []string) bool {
+	if state.role != Candidate || state.currentTerm != term {
+		return false
+	}
+
+	state.LeaderId = leaderId
+	state.NextIndex = make(map[string]uint32)
+	state.MatchIndex = make(map[string]uint32)
+	state.LastContact = make(map[string]time.Time)
+	state.transferTarget = ""
+	state.timeoutNowSent = false
+	state.resetReadRounds()
+	state.resetLease()
+	for _, nodeId := range nodeIds {
+		state.NextIndex[nodeId] = state.LogLength() + 1
+		state.MatchIndex[nodeId] = 0
+		state.LastContact[nodeId] = time.Now()
+	}
+	state.setRole(Leader)
+	return true
+}
+
+// CheckQuorum makes the leader a follower if it hasn't received a response
+// from a quorum of the nodes within the given duration, since it may be on
+// the minority side of a partition. Nodes are given the full duration from
+// when the leader started tracking them. It returns true if the node is still
+// the leader.
+func (state *NodeState) CheckQuorum(leaderId string, within time.Duration) bool {
+	if state.role != Leader {
+		return false
+	}
+
+	hasQuorum := state.membership.HasQuorum(func(nodeId string) bool {
+		lastContact, ok := state.LastContact[nodeId]
+		return nodeId == leaderId || (ok && time.Since(lastContact) < within)
+	})
+	if hasQuorum {
+		return true
+	}
+
+	global.Log.Warningf("Stepping down after not hearing from a quorum within %v", within)
+	state.LeaderId = ""
+	state.setRole(Follower)
+	return false
+}
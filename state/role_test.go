@@ -0,0 +1,134 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func Test_BecomeCandidate_WhenFollower_StartsNewTermAndVotesForSelf(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.SetCurrentTerm(3)
+	roleChanged := node.RoleChanged()
+
+	term, ok := node.BecomeCandidate("1")
+	if !ok {
+		t.Fatal("BecomeCandidate returned false")
+	}
+
+	if term != 4 || node.CurrentTerm() != 4 {
+		t.Error("Term was not 4:", term, node.CurrentTerm())
+	}
+	if node.VotedFor() != "1" {
+		t.Error("VotedFor was not 1:", node.VotedFor())
+	}
+	if node.Role() != Candidate {
+		t.Error("Role was not Candidate:", node.Role())
+	}
+
+	select {
+	case <-roleChanged:
+	default:
+		t.Error("RoleChanged was not notified")
+	}
+}
+
+func Test_BecomeCandidate_WhenAlreadyCandidate_NotifiesRoleChanged(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.BecomeCandidate("1")
+	roleChanged := node.RoleChanged()
+
+	term, ok := node.BecomeTransferCandidate("1")
+	if !ok || term != 2 {
+		t.Fatal("BecomeTransferCandidate did not start term 2:", term, ok)
+	}
+
+	select {
+	case <-roleChanged:
+	default:
+		t.Error("RoleChanged was not notified of the new term")
+	}
+}
+
+func Test_BecomeLeader_WhenCandidateInTerm_InitializesNextIndexAndMatchIndex(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.SetLogEntry(1, LogEntry{"a", "A", 0})
+	term, _ := node.BecomeCandidate("1")
+
+	if node.BecomeLeader(term+1, "1", []string{"1", "2"}) {
+		t.Error("BecomeLeader succeeded for a different term")
+	}
+
+	if !node.BecomeLeader(term, "1", []string{"1", "2"}) {
+		t.Fatal("BecomeLeader returned false")
+	}
+
+	if node.Role() != Leader || node.LeaderId != "1" {
+		t.Error("Node did not become leader:", node.Role(), node.LeaderId)
+	}
+	if node.NextIndex["2"] != 2 || node.MatchIndex["2"] != 0 {
+		t.Error("NextIndex and MatchIndex were not 2 and 0:", node.NextIndex["2"], node.MatchIndex["2"])
+	}
+
+	if _, ok := node.BecomeCandidate("1"); ok {
+		t.Error("BecomeCandidate succeeded for a leader")
+	}
+}
+
+func Test_StepDown_WithTerms_OnlyStepsDownForNewerTerm(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", []string{"1"})
+
+	if node.StepDown(term) {
+		t.Error("StepDown succeeded for the current term")
+	}
+	if node.Role() != Leader {
+		t.Error("Role was not Leader:", node.Role())
+	}
+
+	if !node.StepDown(term + 1) {
+		t.Error("StepDown failed for a newer term")
+	}
+	if node.Role() != Follower || node.CurrentTerm() != term+1 || node.VotedFor() != "" {
+		t.Error("Node did not step down:", node.Role(), node.CurrentTerm(), node.VotedFor())
+	}
+}
+
+func Test_CheckQuorum_WithoutRecentContactFromMajority_StepsDown(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.Bootstrap(map[string]global.NodeHost{"1": {}, "2": {}, "3": {}})
+	term, _ := node.BecomeCandidate("1")
+	node.BecomeLeader(term, "1", node.Membership().NodeIds())
+
+	// The followers are given time to respond after the node becomes leader.
+	if !node.CheckQuorum("1", time.Second) {
+		t.Error("Leader stepped down right after being elected")
+	}
+
+	node.LastContact["2"] = time.Now().Add(-2 * time.Second)
+	if !node.CheckQuorum("1", time.Second) || node.Role() != Leader {
+		t.Error("Leader stepped down after hearing from a majority")
+	}
+
+	node.LastContact["3"] = time.Now().Add(-2 * time.Second)
+	if node.CheckQuorum("1", time.Second) || node.Role() != Follower {
+		t.Error("Leader did not step down without hearing from a majority:", node.Role())
+	}
+}
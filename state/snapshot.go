@@ -0,0 +1,233 @@
+package state
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+// The number of snapshots kept in the snapshot store. Older snapshots are only
+// kept in case the newest one can't be read.
+const retainedSnapshots int = 2
+
+// ErrSnapshotMissing is returned when entries that haven't been applied were
+// removed from the log, but there is no snapshot that includes them.
+var ErrSnapshotMissing = errors.New("the log was compacted past LastApplied, but there is no snapshot")
+
+// LatestSnapshot returns the metadata of the latest snapshot taken or
+// installed on the node, or nil if there is none.
+func (state *NodeState) LatestSnapshot() (*SnapshotMeta, error) {
+	return latestSnapshot(state.SnapshotStore)
+}
+
+// SnapshotIndex returns the index of the last entry included in the latest
+// snapshot, or 0 if there is none.
+func (state *NodeState) SnapshotIndex() uint32 {
+	return state.snapshotIndex
+}
+
+// setLogOffset sets the index and term of the last entry removed from the log
+// in memory and in the node state machine.
+func (state *NodeState) setLogOffset(index uint32, term uint32) error {
+	err := state.NodeDataStore.Put(logOffset, strconv.Itoa(int(index)))
+	if err != nil {
+		return err
+	}
+	err = state.NodeDataStore.Put(logOffsetTerm, strconv.Itoa(int(term)))
+	if err != nil {
+		return err
+	}
+
+	state.logOffset = index
+	state.logOffsetTerm = term
+	return nil
+}
+
+// CompactLog removes the entries up to and including the given index from the
+// front of the log. The entries must already be included in a snapshot.
+func (state *NodeState) CompactLog(index uint32) error {
+	if index <= state.logOffset || index > state.LogLength() {
+		return nil
+	}
+
+	// The membership as of the new offset can't be found in the log anymore.
+	baseMembership, _ := state.membershipAt(index)
+
+	// The new offset is stored before the entries are removed so that a crash
+	// in between leaves extra entries behind rather than a gap in the log.
+	oldOffset := state.logOffset
+	err := state.setLogOffset(index, state.LogTerm(index))
+	if err != nil {
+		return err
+	}
+
+	for i := oldOffset + 1; i <= index; i++ {
+		err = state.NodeDataStore.Put(strconv.Itoa(int(i)), "")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Copy the remaining entries so that the compacted ones can be freed.
+	remaining := make([]LogEntry, len(*state.log)-int(index-oldOffset))
+	copy(remaining, (*state.log)[index-oldOffset:])
+	*state.log = remaining
+	state.baseMembership = baseMembership
+	state.updateMembership()
+
+	global.Log.Debugf("Compacted log up to index %d", index)
+	return nil
+}
+
+// discardLog removes every entry from the log and sets the log offset to the
+// given index and term, so that the next entry appended has index + 1. The
+// membership becomes the given one, as of the index.
+func (state *NodeState) discardLog(index uint32, term uint32, membership Membership) error {
+	oldOffset := state.logOffset
+	lastIndex := state.LogLength()
+	err := state.setLogOffset(index, term)
+	if err != nil {
+		return err
+	}
+
+	for i := oldOffset + 1; i <= lastIndex; i++ {
+		err = state.NodeDataStore.Put(strconv.Itoa(int(i)), "")
+		if err != nil {
+			return err
+		}
+	}
+
+	*state.log = []LogEntry{}
+	state.baseMembership = membership
+	state.updateMembership()
+	return nil
+}
+
+// TakeSnapshot snapshots the storage data store at LastApplied and compacts
+// the log, keeping up to trailingEntries entries before LastApplied so that
+// followers that are slightly behind can still catch up from the log.
+//
+// It must only be called from the goroutine that applies entries, so that
+// the storage data store doesn't change while it is being written. The lock
+// must not be held by the caller.
+func (state *NodeState) TakeSnapshot(trailingEntries uint32) error {
+	state.Lock()
+	index := state.LastApplied
+	if index <= state.snapshotIndex {
+		state.Unlock()
+		return nil
+	}
+	term := state.LogTerm(index)
+	membership, _ := state.membershipAt(index)
+	state.Unlock()
+
+	sink, err := state.SnapshotStore.Create(index, term, membership)
+	if err != nil {
+		return err
+	}
+	dataWriter := NewSnapshotDataWriter(sink)
+	err = state.StorageDataStore.ForEach(dataWriter.Write)
+	if err == nil {
+		err = dataWriter.Flush()
+	}
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	err = sink.Close()
+	if err != nil {
+		return err
+	}
+	global.Log.Infof("Took snapshot at index %d", index)
+
+	state.Lock()
+	// A snapshot from the leader may have been installed in the meantime.
+	if index > state.snapshotIndex {
+		state.snapshotIndex = index
+		if index > trailingEntries {
+			err = state.CompactLog(index - trailingEntries)
+		}
+	}
+	state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return state.SnapshotStore.Retain(retainedSnapshots)
+}
+
+// InstallSnapshot replaces the log entries covered by a snapshot received from
+// the leader, which must already be in the snapshot store. If the log contains
+// the snapshot's last included entry, the entries following it are kept;
+// otherwise the whole log is discarded. The applier loads the snapshot into
+// the storage data store afterward.
+func (state *NodeState) InstallSnapshot(meta SnapshotMeta) error {
+	index := meta.LastIncludedIndex
+	logContainsSnapshot := state.logContains(index, meta.LastIncludedTerm)
+
+	// Entries that are already committed are either in the log or in an
+	// earlier snapshot, so there is nothing to install, unless a crash while
+	// the snapshot was being installed left a log that doesn't reach it.
+	if index < state.snapshotIndex || (index <= state.CommitIndex && logContainsSnapshot) {
+		return nil
+	}
+
+	state.snapshotIndex = index
+	global.Log.Infof("Installed snapshot at index %d", index)
+
+	var err error
+	if logContainsSnapshot {
+		err = state.CompactLog(index)
+	} else {
+		err = state.discardLog(index, meta.LastIncludedTerm, meta.Membership)
+	}
+	if err != nil {
+		return err
+	}
+
+	if index > state.CommitIndex {
+		state.SetCommitIndex(index)
+	}
+	return state.SnapshotStore.Retain(retainedSnapshots)
+}
+
+// logContains returns true if the log reaches the entry with the given index
+// and term. Entries removed by compaction were committed, so the log is
+// considered to contain them.
+func (state *NodeState) logContains(index uint32, term uint32) bool {
+	if index < state.logOffset {
+		return true
+	}
+	return index <= state.LogLength() && state.LogTerm(index) == term
+}
+
+// restoreSnapshot replaces the contents of the storage data store with the
+// latest snapshot and advances LastApplied to its last included index. The
+// lock must not be held by the caller.
+func (state *NodeState) restoreSnapshot() error {
+	meta, err := state.LatestSnapshot()
+	if err != nil {
+		return err
+	} else if meta == nil {
+		return ErrSnapshotMissing
+	}
+
+	_, reader, err := state.SnapshotStore.Open(meta.Id)
+	if err != nil {
+		return err
+	}
+	err = state.StorageDataStore.ReplaceAll(NewSnapshotDataReader(reader).Next)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	global.Log.Infof("Restored snapshot at index %d", meta.LastIncludedIndex)
+
+	state.Lock()
+	if meta.LastIncludedIndex > state.LastApplied {
+		state.SetLastApplied(meta.LastIncludedIndex)
+	}
+	state.Unlock()
+	return nil
+}
@@ -0,0 +1,195 @@
+package state
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"time"
+)
+
+// SnapshotMeta describes a snapshot of the storage data store.
+type SnapshotMeta struct {
+	// Uniquely identifies the snapshot within its SnapshotStore.
+	Id string
+
+	// The index and term of the last log entry included in the snapshot.
+	LastIncludedIndex uint32
+	LastIncludedTerm  uint32
+
+	// The cluster membership as of the last included entry.
+	Membership Membership
+
+	// The size of the snapshot data in bytes and its CRC-32C checksum.
+	Size     int64
+	Checksum uint32
+}
+
+// SnapshotReader reads the data of a stored snapshot.
+type SnapshotReader interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// SnapshotSink is used to write the data of a new snapshot. The snapshot is
+// only added to its SnapshotStore once Close succeeds.
+type SnapshotSink interface {
+	io.Writer
+
+	// Meta returns the metadata of the snapshot being written.
+	Meta() SnapshotMeta
+
+	// Close finishes writing the snapshot and adds it to the store.
+	Close() error
+
+	// Cancel discards the snapshot.
+	Cancel() error
+}
+
+// SnapshotStore represents any kind of storage for snapshots.
+type SnapshotStore interface {
+	// Create starts a new snapshot with the given index, term, and membership.
+	Create(lastIncludedIndex uint32, lastIncludedTerm uint32, membership Membership) (SnapshotSink, error)
+
+	// List returns the metadata of the stored snapshots, newest first.
+	List() ([]SnapshotMeta, error)
+
+	// Open returns the metadata and data of the snapshot with the given id.
+	// The caller must close the returned reader.
+	Open(id string) (SnapshotMeta, SnapshotReader, error)
+
+	// Retain removes all but the newest count snapshots.
+	Retain(count int) error
+}
+
+// Snapshot data is verified using CRC-32C checksums.
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// UpdateChecksum returns the CRC-32C checksum of data appended to data with
+// the checksum crc.
+func UpdateChecksum(crc uint32, data []byte) uint32 {
+	return crc32.Update(crc, checksumTable, data)
+}
+
+// newSnapshotId returns an id for a new snapshot, which includes the time so
+// that snapshots with the same index are distinguishable.
+func newSnapshotId(lastIncludedIndex uint32, lastIncludedTerm uint32) string {
+	return fmt.Sprintf("%d-%d-%d", lastIncludedTerm, lastIncludedIndex, time.Now().UnixNano())
+}
+
+// sortSnapshots sorts the snapshots newest first, by last included index and
+// then by id.
+func sortSnapshots(snapshots []SnapshotMeta) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].LastIncludedIndex != snapshots[j].LastIncludedIndex {
+			return snapshots[i].LastIncludedIndex > snapshots[j].LastIncludedIndex
+		}
+		return snapshots[i].Id > snapshots[j].Id
+	})
+}
+
+// latestSnapshot returns the metadata of the newest snapshot in the store, or
+// nil if there are no snapshots.
+func latestSnapshot(store SnapshotStore) (*SnapshotMeta, error) {
+	snapshots, err := store.List()
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return &snapshots[0], nil
+}
+
+// Snapshot data is a sequence of records, one for each key-value pair, made
+// up of the length of the key, the key, the length of the value, and the
+// value, with the lengths as big-endian uint32s. Records are written and read
+// one at a time, so that snapshots never have to fit in memory.
+
+// SnapshotDataWriter writes key-value pairs as snapshot data.
+type SnapshotDataWriter struct {
+	writer *bufio.Writer
+}
+
+// NewSnapshotDataWriter returns a SnapshotDataWriter that writes to writer.
+// Flush must be called after the last key-value pair is written.
+func NewSnapshotDataWriter(writer io.Writer) *SnapshotDataWriter {
+	return &SnapshotDataWriter{writer: bufio.NewWriter(writer)}
+}
+
+// Write writes the record for a key-value pair.
+func (w *SnapshotDataWriter) Write(key string, value string) error {
+	err := w.writeField(key)
+	if err != nil {
+		return err
+	}
+	return w.writeField(value)
+}
+
+func (w *SnapshotDataWriter) writeField(field string) error {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(field)))
+	_, err := w.writer.Write(length[:])
+	if err != nil {
+		return err
+	}
+	_, err = w.writer.WriteString(field)
+	return err
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (w *SnapshotDataWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// SnapshotDataReader reads key-value pairs written by a SnapshotDataWriter.
+type SnapshotDataReader struct {
+	reader *bufio.Reader
+}
+
+// NewSnapshotDataReader returns a SnapshotDataReader that reads from reader.
+func NewSnapshotDataReader(reader io.Reader) *SnapshotDataReader {
+	return &SnapshotDataReader{reader: bufio.NewReader(reader)}
+}
+
+// Next reads the next key-value pair, returning io.EOF once every pair has
+// been read.
+func (r *SnapshotDataReader) Next() (string, string, error) {
+	key, err := r.readField()
+	if err != nil {
+		return "", "", err
+	}
+	value, err := r.readField()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return key, value, err
+}
+
+// readField reads a length-prefixed field, returning io.EOF only if there is
+// no more data at all.
+func (r *SnapshotDataReader) readField() (string, error) {
+	var length [4]byte
+	_, err := io.ReadFull(r.reader, length[:])
+	if err != nil {
+		return "", err
+	}
+
+	field := make([]byte, binary.BigEndian.Uint32(length[:]))
+	_, err = io.ReadFull(r.reader, field)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return string(field), err
+}
+
+// WriteSnapshotData writes the key-value pairs as snapshot data.
+func WriteSnapshotData(writer io.Writer, values map[string]string) error {
+	dataWriter := NewSnapshotDataWriter(writer)
+	for key, value := range values {
+		err := dataWriter.Write(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return dataWriter.Flush()
+}
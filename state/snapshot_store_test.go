@@ -0,0 +1,63 @@
+package state
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// readSnapshotData reads every key-value pair from snapshot data.
+func readSnapshotData(reader io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	dataReader := NewSnapshotDataReader(reader)
+	for {
+		key, value, err := dataReader.Next()
+		if err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+}
+
+func Test_SnapshotDataReader_WithWrittenRecords_ReadsRecordsInOrder(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewSnapshotDataWriter(&buffer)
+	writer.Write("a", "A")
+	writer.Write("", "empty key")
+	writer.Write("c", "")
+	err := writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewSnapshotDataReader(&buffer)
+	for _, expected := range [][2]string{{"a", "A"}, {"", "empty key"}, {"c", ""}} {
+		key, value, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != expected[0] || value != expected[1] {
+			t.Errorf("Record was not %q: %q", expected, [2]string{key, value})
+		}
+	}
+
+	_, _, err = reader.Next()
+	if err != io.EOF {
+		t.Error("Reading past the last record did not return io.EOF:", err)
+	}
+}
+
+func Test_SnapshotDataReader_WithTruncatedRecord_ReturnsUnexpectedEOF(t *testing.T) {
+	var buffer bytes.Buffer
+	WriteSnapshotData(&buffer, map[string]string{"key": "value"})
+
+	for length := 1; length < buffer.Len(); length++ {
+		reader := NewSnapshotDataReader(bytes.NewReader(buffer.Bytes()[:length]))
+		_, _, err := reader.Next()
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("Reading a record truncated to %d bytes did not return io.ErrUnexpectedEOF: %v", length, err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package state
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/thomasylee/GoRaft/global"
+)
+
+func Test_TakeSnapshot_WithAppliedEntries_SavesSnapshotAndCompactsLog(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{"b", "B", 1})
+	node.SetLogEntry(3, LogEntry{"c", "C", 2})
+	node.SetLogEntry(4, LogEntry{"d", "D", 2})
+	node.SetCommitIndex(3)
+
+	err := node.ApplyCommittedEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = node.TakeSnapshot(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := node.LatestSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	} else if meta == nil {
+		t.Fatal("Snapshot was not saved")
+	}
+	if meta.LastIncludedIndex != 3 || meta.LastIncludedTerm != 2 {
+		t.Errorf("Snapshot index and term were not 3 and 2: %d and %d", meta.LastIncludedIndex, meta.LastIncludedTerm)
+	}
+
+	_, reader, err := node.SnapshotStore.Open(meta.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := readSnapshotData(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 || data["c"] != "C" {
+		t.Error("Snapshot data was not the applied entries:", data)
+	}
+
+	// One entry before LastApplied is kept in the log.
+	if node.LogOffset() != 2 {
+		t.Error("LogOffset was not 2:", node.LogOffset())
+	}
+	if node.LogLength() != 4 {
+		t.Error("LogLength was not 4:", node.LogLength())
+	}
+	if node.LogTerm(2) != 1 {
+		t.Error("LogTerm at the offset was not 1:", node.LogTerm(2))
+	}
+	if node.Log(3) != (LogEntry{"c", "C", 2}) {
+		t.Error("Log entry 3 was not kept:", node.Log(3))
+	}
+	for i := 1; i <= 2; i++ {
+		value, _ := node.NodeDataStore.Get(strconv.Itoa(i))
+		if value != "" {
+			t.Errorf("Log entry %d was not removed from the data store: %s", i, value)
+		}
+	}
+}
+
+func Test_NewNodeState_WithCompactedLog_LoadsSnapshotAndRemainingEntries(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{"b", "B", 1})
+	node.SetLogEntry(3, LogEntry{"c", "C", 1})
+	node.SetCommitIndex(2)
+	node.ApplyCommittedEntries()
+	node.TakeSnapshot(0)
+
+	// The storage data store is empty, as if it were lost, so it must be
+	// restored from the snapshot.
+	restarted := NewNodeState(node.NodeDataStore, NewMemoryDataStore(), node.SnapshotStore)
+
+	if restarted.LogOffset() != 2 || restarted.LogLength() != 3 {
+		t.Fatalf("LogOffset and LogLength were not 2 and 3: %d and %d", restarted.LogOffset(), restarted.LogLength())
+	}
+	if restarted.Log(3) != (LogEntry{"c", "C", 1}) {
+		t.Error("Log entry 3 was not loaded:", restarted.Log(3))
+	}
+	if restarted.CommitIndex != 2 {
+		t.Error("CommitIndex was not 2:", restarted.CommitIndex)
+	}
+
+	err := restarted.ApplyCommittedEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted.LastApplied != 2 {
+		t.Error("LastApplied was not 2:", restarted.LastApplied)
+	}
+	value, _ := restarted.StorageDataStore.Get("b")
+	if value != "B" {
+		t.Errorf("Value for key b was not %q: %q", "B", value)
+	}
+}
+
+func Test_InstallSnapshot_WhenLogDoesNotContainSnapshot_DiscardsLog(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{"b", "B", 1})
+
+	sink, _ := node.SnapshotStore.Create(5, 3, Membership{})
+	WriteSnapshotData(sink, map[string]string{"x": "X"})
+	sink.Close()
+
+	err := node.InstallSnapshot(sink.Meta())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.LogOffset() != 5 || node.LogLength() != 5 {
+		t.Errorf("LogOffset and LogLength were not 5: %d and %d", node.LogOffset(), node.LogLength())
+	}
+	if node.LastLogTerm() != 3 {
+		t.Error("LastLogTerm was not 3:", node.LastLogTerm())
+	}
+	if node.CommitIndex != 5 {
+		t.Error("CommitIndex was not 5:", node.CommitIndex)
+	}
+
+	err = node.ApplyCommittedEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.LastApplied != 5 {
+		t.Error("LastApplied was not 5:", node.LastApplied)
+	}
+	value, _ := node.StorageDataStore.Get("x")
+	if value != "X" {
+		t.Errorf("Value for key x was not %q: %q", "X", value)
+	}
+}
+
+func Test_NewNodeState_WhenLogDoesNotReachSnapshot_DiscardsLog(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	// A crash after the snapshot was saved but before the log was discarded
+	// leaves a log that ends before the snapshot.
+	node := createNodeState()
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{"b", "B", 1})
+
+	sink, _ := node.SnapshotStore.Create(5, 3, Membership{})
+	WriteSnapshotData(sink, map[string]string{"x": "X"})
+	sink.Close()
+
+	restarted := NewNodeState(node.NodeDataStore, NewMemoryDataStore(), node.SnapshotStore)
+
+	if restarted.LogOffset() != 5 || restarted.LogLength() != 5 {
+		t.Errorf("LogOffset and LogLength were not 5: %d and %d", restarted.LogOffset(), restarted.LogLength())
+	}
+	if restarted.LastLogTerm() != 3 {
+		t.Error("LastLogTerm was not 3:", restarted.LastLogTerm())
+	}
+	if restarted.CommitIndex != 5 {
+		t.Error("CommitIndex was not 5:", restarted.CommitIndex)
+	}
+}
+
+func Test_InstallSnapshot_WhenSnapshotIsInstalledButLogDoesNotReachIt_DiscardsLog(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	node := createNodeState()
+	node.SetLogEntry(1, LogEntry{"a", "A", 1})
+	node.SetLogEntry(2, LogEntry{"b", "B", 1})
+
+	sink, _ := node.SnapshotStore.Create(5, 3, Membership{})
+	WriteSnapshotData(sink, map[string]string{"x": "X"})
+	sink.Close()
+
+	// The snapshot was recorded as installed, but the log was not discarded.
+	node.snapshotIndex = 5
+	node.CommitIndex = 5
+
+	err := node.InstallSnapshot(sink.Meta())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.LogOffset() != 5 || node.LogLength() != 5 {
+		t.Errorf("LogOffset and LogLength were not 5: %d and %d", node.LogOffset(), node.LogLength())
+	}
+	if node.LastLogTerm() != 3 {
+		t.Error("LastLogTerm was not 3:", node.LastLogTerm())
+	}
+}
+
+func Test_ApplyCommittedEntries_WhenSnapshotIsMissing_ReturnsErrSnapshotMissing(t *testing.T) {
+	global.SetUpLogger()
+	global.SetLogLevel("critical")
+
+	// The log was compacted past LastApplied, but the snapshot was lost.
+	node := createNodeState()
+	node.snapshotIndex = 5
+	node.CommitIndex = 5
+
+	err := node.ApplyCommittedEntries()
+	if err != ErrSnapshotMissing {
+		t.Error("Error was not ErrSnapshotMissing:", err)
+	}
+}